@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"net"
+
+	"github.com/allabout/cloud-run-sdk/grpc"
+	"github.com/allabout/cloud-run-sdk/http"
+	"golang.org/x/sync/errgroup"
+)
+
+// Worker is a long-running process such as a server or a pub/sub puller.
+// It must return when ctx is done.
+type Worker func(ctx context.Context) error
+
+// Runner runs multiple servers and workers concurrently under one lifecycle.
+type Runner struct {
+	workers []Worker
+}
+
+func NewRunner() *Runner {
+	return &Runner{}
+}
+
+// Add registers arbitrary background workers.
+func (r *Runner) Add(workers ...Worker) {
+	r.workers = append(r.workers, workers...)
+}
+
+func (r *Runner) AddHTTPServer(s *http.Server) {
+	r.Add(s.Run)
+}
+
+func (r *Runner) AddGRPCServer(s *grpc.Server, lis net.Listener) {
+	r.Add(func(ctx context.Context) error {
+		return s.Run(ctx, lis)
+	})
+}
+
+// Run starts all the workers and blocks until all of them return.
+// When ctx is done, e.g. by util.SetupSignalContext, or any worker fails, all the workers are shut down.
+// It returns the first error returned by the workers.
+func (r *Runner) Run(ctx context.Context) error {
+	eg, ctx := errgroup.WithContext(ctx)
+
+	for _, w := range r.workers {
+		w := w
+		eg.Go(func() error {
+			return w(ctx)
+		})
+	}
+
+	return eg.Wait()
+}
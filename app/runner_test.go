@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunner(t *testing.T) {
+	errWorker := errors.New("worker failed")
+
+	tests := []struct {
+		workers []Worker
+		cancel  bool
+		wantErr error
+	}{
+		{
+			workers: []Worker{
+				func(ctx context.Context) error {
+					<-ctx.Done()
+					return nil
+				},
+				func(ctx context.Context) error {
+					<-ctx.Done()
+					return nil
+				},
+			},
+			cancel:  true,
+			wantErr: nil,
+		},
+		{
+			workers: []Worker{
+				func(ctx context.Context) error {
+					<-ctx.Done()
+					return nil
+				},
+				func(ctx context.Context) error {
+					return errWorker
+				},
+			},
+			cancel:  false,
+			wantErr: errWorker,
+		},
+	}
+
+	for _, tt := range tests {
+		r := NewRunner()
+		r.Add(tt.workers...)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- r.Run(ctx)
+		}()
+
+		if tt.cancel {
+			cancel()
+		}
+
+		select {
+		case err := <-errCh:
+			if want, got := tt.wantErr, err; want != got {
+				t.Errorf("want %v, got %v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Error("Run doesn't return")
+		}
+
+		cancel()
+	}
+}
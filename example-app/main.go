@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"os"
 
 	"github.com/allabout/cloud-run-sdk/http"
 	"github.com/allabout/cloud-run-sdk/logging/zerolog"
@@ -21,5 +22,7 @@ func main() {
 	server := http.NewServerWithLogger("google-sample-project")
 	server.HandleWithRoot(http.AppHandler(fn))
 
-	server.Start(util.SetupSignalHandler())
+	if err := server.Start(util.SetupSignalHandler()); err != nil {
+		os.Exit(1)
+	}
 }
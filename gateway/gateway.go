@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/allabout/cloud-run-sdk/util"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RegisterFunc is the signature of Register<Service>Handler generated by protoc-gen-grpc-gateway.
+type RegisterFunc func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+
+// NewHandler returns http.Handler which transcodes JSON/HTTP requests to the gRPC services registered by fns.
+// conn is usually connected to grpc.Server in the same process.
+// Mount it by http.Server.HandleWithMiddleware to share the middlewares such as InjectLogger,
+// and the trace context is forwarded to gRPC, so the logs of REST call and gRPC handler are correlated.
+func NewHandler(ctx context.Context, conn *grpc.ClientConn, fns []RegisterFunc, opts ...runtime.ServeMuxOption) (http.Handler, error) {
+	opts = append([]runtime.ServeMuxOption{runtime.WithMetadata(traceMetadata)}, opts...)
+	mux := runtime.NewServeMux(opts...)
+
+	for _, fn := range fns {
+		if err := fn(ctx, mux, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	return mux, nil
+}
+
+// traceMetadata forwards the trace context, which is not forwarded by the default header matcher.
+// The span in ctx, e.g. the server span started by InjectLogger, is preferred to the raw headers.
+func traceMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	sc := util.SpanContextFromContext(ctx)
+	if sc == nil {
+		sc = util.GetSpanContextFromHTTPHeader(r.Header)
+	}
+	if sc == nil {
+		return metadata.MD{}
+	}
+
+	md := metadata.Pairs("x-cloud-trace-context", sc.CloudTraceContext())
+	if traceparent := sc.Traceparent(); traceparent != "" {
+		md.Set("traceparent", traceparent)
+		if sc.TraceState != "" {
+			md.Set("tracestate", sc.TraceState)
+		}
+	}
+
+	return md
+}
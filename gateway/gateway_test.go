@@ -0,0 +1,172 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	sdkgrpc "github.com/allabout/cloud-run-sdk/grpc"
+	sdkhttp "github.com/allabout/cloud-run-sdk/http"
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/interop"
+	pb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const buffsize = 1024
+
+func TestMain(m *testing.M) {
+	if err := os.Setenv("K_CONFIGURATION", "true"); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+// registerEmptyCall imitates the code generated by protoc-gen-grpc-gateway for "GET /v1/empty".
+func registerEmptyCall(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	client := pb.NewTestServiceClient(conn)
+	pattern := runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"v1", "empty"}, ""))
+
+	mux.Handle(http.MethodGet, pattern, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		ctx, err := runtime.AnnotateContext(r.Context(), mux, r, "/grpc.testing.TestService/EmptyCall")
+		if err != nil {
+			runtime.HTTPError(ctx, mux, &runtime.JSONPb{}, w, r, err)
+			return
+		}
+
+		resp, err := client.EmptyCall(ctx, &pb.Empty{})
+		if err != nil {
+			runtime.HTTPError(ctx, mux, &runtime.JSONPb{}, w, r, err)
+			return
+		}
+
+		runtime.ForwardResponseMessage(ctx, mux, &runtime.JSONPb{}, w, r, resp)
+	})
+
+	return nil
+}
+
+func TestNewHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	logFn := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		zerolog.Ctx(ctx).Info("message")
+		return handler(ctx, req)
+	}
+
+	grpcServer := sdkgrpc.NewServer("google-sample-project", sdkgrpc.WithUnaryInterceptors(logFn))
+	pb.RegisterTestServiceServer(grpcServer.Srv, interop.NewTestServer())
+
+	lis := bufconn.Listen(buffsize)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go grpcServer.Start(lis, stopCh)
+
+	ctx := context.Background()
+	dial := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithInsecure(), grpc.WithContextDialer(dial))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	gw, err := NewHandler(ctx, conn, []RegisterFunc{registerEmptyCall})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/empty", nil)
+	req.Header.Set("X-Cloud-Trace-Context", "0123456789abcdef0123456789abcdef/123;o=1")
+	got := httptest.NewRecorder()
+
+	sdkhttp.Chain(gw, sdkhttp.InjectLogger("google-sample-project")).ServeHTTP(got, req)
+
+	if want, got := http.StatusOK, got.Code; want != got {
+		t.Errorf("want %d, got %d", want, got)
+	}
+	if want, got := "{}", strings.TrimSpace(got.Body.String()); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	wantLog := `{"severity":"INFO","method":"/grpc.testing.TestService/EmptyCall","logging.googleapis.com/trace":"projects/google-sample-project/traces/0123456789abcdef0123456789abcdef","logging.googleapis.com/spanId":"000000000000007b","logging.googleapis.com/trace_sampled":true,"message":"message"}` + "\n"
+	if want, got := wantLog, buf.String(); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestNewHandlerWithSDKConn(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	mdCh := make(chan metadata.MD, 1)
+	logFn := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		mdCh <- md
+		zerolog.Ctx(ctx).Info("message")
+		return handler(ctx, req)
+	}
+
+	grpcServer := sdkgrpc.NewServer("google-sample-project", sdkgrpc.WithUnaryInterceptors(logFn))
+	pb.RegisterTestServiceServer(grpcServer.Srv, interop.NewTestServer())
+
+	lis := bufconn.Listen(buffsize)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go grpcServer.Start(lis, stopCh)
+
+	ctx := context.Background()
+	dial := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	// TraceIDInterceptor of the connection also propagates the trace context
+	conn, err := sdkgrpc.NewInsecureConn(ctx, "bufnet", grpc.WithContextDialer(dial))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	gw, err := NewHandler(ctx, conn, []RegisterFunc{registerEmptyCall})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/empty", nil)
+	req.Header.Set("X-Cloud-Trace-Context", "0123456789abcdef0123456789abcdef/123;o=1")
+	got := httptest.NewRecorder()
+
+	sdkhttp.Chain(gw, sdkhttp.InjectLogger("google-sample-project")).ServeHTTP(got, req)
+
+	if want, got := http.StatusOK, got.Code; want != got {
+		t.Errorf("want %d, got %d", want, got)
+	}
+
+	// the trace context is sent once
+	md := <-mdCh
+	for key, want := range map[string]string{
+		"x-cloud-trace-context": "0123456789abcdef0123456789abcdef/123;o=1",
+		"traceparent":           "00-0123456789abcdef0123456789abcdef-000000000000007b-01",
+	} {
+		if got := md.Get(key); len(got) != 1 || got[0] != want {
+			t.Errorf("%s: want %q, got %q", key, want, got)
+		}
+	}
+
+	wantLog := `{"severity":"INFO","method":"/grpc.testing.TestService/EmptyCall","logging.googleapis.com/trace":"projects/google-sample-project/traces/0123456789abcdef0123456789abcdef","logging.googleapis.com/spanId":"000000000000007b","logging.googleapis.com/trace_sampled":true,"message":"message"}` + "\n"
+	if want, got := wantLog, buf.String(); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
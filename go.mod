@@ -4,8 +4,15 @@ go 1.15
 
 require (
 	cloud.google.com/go v0.82.0
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.5.0
 	github.com/rs/zerolog v1.22.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	golang.org/x/net v0.0.0-20210503060351-7fd8e65b6420
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	google.golang.org/api v0.47.0
-	google.golang.org/genproto v0.0.0-20210517163617-5e0236093d7a
+	google.golang.org/genproto v0.0.0-20210617175327-b9e0b3197ced
 	google.golang.org/grpc v1.38.0
+	google.golang.org/protobuf v1.26.0
 )
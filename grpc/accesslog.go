@@ -0,0 +1,96 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// AccessLogInterceptor logs the status code, latency, peer address and message sizes of each call.
+func AccessLogInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		zerolog.Ctx(ctx).LogGRPCRequest(&zerolog.GRPCRequest{
+			Method:       info.FullMethod,
+			Code:         status.Code(err),
+			Latency:      time.Since(start),
+			PeerAddress:  peerAddress(ctx),
+			RequestSize:  messageSize(req),
+			ResponseSize: messageSize(resp),
+		})
+
+		return resp, err
+	}
+}
+
+// StreamAccessLogInterceptor is the streaming counterpart of AccessLogInterceptor.
+// The message sizes are the total of the messages received and sent on the stream.
+func StreamAccessLogInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		stream := &sizeRecordingStream{ServerStream: ss}
+
+		err := handler(srv, stream)
+
+		ctx := ss.Context()
+		zerolog.Ctx(ctx).LogGRPCRequest(&zerolog.GRPCRequest{
+			Method:       info.FullMethod,
+			Code:         status.Code(err),
+			Latency:      time.Since(start),
+			PeerAddress:  peerAddress(ctx),
+			RequestSize:  stream.recvSize,
+			ResponseSize: stream.sendSize,
+		})
+
+		return err
+	}
+}
+
+func peerAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	return p.Addr.String()
+}
+
+func messageSize(m interface{}) int64 {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return 0
+	}
+
+	return int64(proto.Size(pm))
+}
+
+// sizeRecordingStream records the total size of the messages received and sent.
+type sizeRecordingStream struct {
+	grpc.ServerStream
+	recvSize int64
+	sendSize int64
+}
+
+func (s *sizeRecordingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sendSize += messageSize(m)
+	}
+	return err
+}
+
+func (s *sizeRecordingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.recvSize += messageSize(m)
+	}
+	return err
+}
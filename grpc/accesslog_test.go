@@ -0,0 +1,141 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type grpcAccessLogEntry struct {
+	Severity    string `json:"severity"`
+	Message     string `json:"message"`
+	GRPCRequest struct {
+		Method       string `json:"method"`
+		Code         string `json:"code"`
+		PeerAddress  string `json:"peerAddress"`
+		RequestSize  string `json:"requestSize"`
+		ResponseSize string `json:"responseSize"`
+	} `json:"grpcRequest"`
+}
+
+func parseAccessLog(t *testing.T, buf *bytes.Buffer) grpcAccessLogEntry {
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+
+	var entry grpcAccessLogEntry
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return entry
+}
+
+func TestAccessLogInterceptor(t *testing.T) {
+	req := &pb.SimpleRequest{Payload: &pb.Payload{Body: []byte("0123456789")}}
+
+	tests := []struct {
+		err          error
+		wantSeverity string
+		wantCode     string
+		wantRespSize string
+	}{
+		{nil, "INFO", "OK", "14"},
+		{status.Error(codes.NotFound, "not found"), "WARNING", "NotFound", "0"},
+		{status.Error(codes.Internal, "internal"), "ERROR", "Internal", "0"},
+	}
+
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		zerolog.SetSharedLogger(buf, true, false)
+
+		unaryInfo := &grpc.UnaryServerInfo{
+			FullMethod: "/grpc.testing.TestService/UnaryCall",
+		}
+
+		unaryHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			if tt.err != nil {
+				return nil, tt.err
+			}
+			return &pb.SimpleResponse{Payload: &pb.Payload{Body: []byte("0123456789")}}, nil
+		}
+
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000}})
+		ctx = zerolog.NewLogger(zerolog.GetSharedLogger()).WithContext(ctx)
+
+		if _, err := AccessLogInterceptor()(ctx, req, unaryInfo, unaryHandler); err != tt.err {
+			t.Fatalf("want %v, got %v", tt.err, err)
+		}
+
+		entry := parseAccessLog(t, buf)
+
+		var want grpcAccessLogEntry
+		want.Severity = tt.wantSeverity
+		want.Message = "/grpc.testing.TestService/UnaryCall " + tt.wantCode
+		want.GRPCRequest.Method = "/grpc.testing.TestService/UnaryCall"
+		want.GRPCRequest.Code = tt.wantCode
+		want.GRPCRequest.PeerAddress = "192.0.2.1:50000"
+		want.GRPCRequest.RequestSize = "14"
+		want.GRPCRequest.ResponseSize = tt.wantRespSize
+
+		if !reflect.DeepEqual(want, entry) {
+			t.Errorf("wrong entry %#v, want %#v", entry, want)
+		}
+	}
+}
+
+type recvStream struct {
+	testServerStream
+}
+
+func (s *recvStream) RecvMsg(m interface{}) error {
+	m.(*pb.StreamingInputCallRequest).Payload = &pb.Payload{Body: []byte("0123456789")}
+	return nil
+}
+
+func TestStreamAccessLogInterceptor(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	streamInfo := &grpc.StreamServerInfo{
+		FullMethod:     "/grpc.testing.TestService/StreamingInputCall",
+		IsClientStream: true,
+	}
+
+	streamHandler := func(srv interface{}, stream grpc.ServerStream) error {
+		for i := 0; i < 2; i++ {
+			if err := stream.RecvMsg(&pb.StreamingInputCallRequest{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx := zerolog.NewLogger(zerolog.GetSharedLogger()).WithContext(context.Background())
+	stream := &recvStream{testServerStream{ctx: ctx}}
+
+	if err := StreamAccessLogInterceptor()(nil, stream, streamInfo, streamHandler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry := parseAccessLog(t, buf)
+
+	if want, got := "INFO", entry.Severity; want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if want, got := "28", entry.GRPCRequest.RequestSize; want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if want, got := "0", entry.GRPCRequest.ResponseSize; want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,61 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"github.com/allabout/cloud-run-sdk/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// VerifyIDTokenInterceptor verifies the Google-signed ID token in authorization metadata, and puts the claims into the context,
+// which can be read by util.IDTokenPayloadFromContext.
+// It returns codes.Unauthenticated if the token is invalid, and codes.PermissionDenied if the caller is not allowed.
+func VerifyIDTokenInterceptor(verifier *util.IDTokenVerifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := verifyIDToken(ctx, verifier)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamVerifyIDTokenInterceptor is the streaming counterpart of VerifyIDTokenInterceptor.
+func StreamVerifyIDTokenInterceptor(verifier *util.IDTokenVerifier) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := verifyIDToken(ss.Context(), verifier)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &wrappedStream{ss, ctx})
+	}
+}
+
+func verifyIDToken(ctx context.Context, verifier *util.IDTokenVerifier) (context.Context, error) {
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+
+	payload, err := verifier.VerifyAuthorization(ctx, authorization)
+	if err != nil {
+		zerolog.Ctx(ctx).Warnf("failed to verify ID token : %v", err)
+
+		// the detail of the error is hidden from the client
+		if errors.Is(err, util.ErrEmailNotAllowed) {
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	return util.ContextWithIDTokenPayload(ctx, payload), nil
+}
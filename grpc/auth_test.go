@@ -0,0 +1,87 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/allabout/cloud-run-sdk/util"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeValidator accepts the tokens of the keys as the ID tokens of the emails.
+type fakeValidator map[string]string
+
+func (v fakeValidator) Validate(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+	email, ok := v[token]
+	if !ok {
+		return nil, errors.New("invalid signature")
+	}
+	return &idtoken.Payload{
+		Issuer:   "https://accounts.google.com",
+		Audience: audience,
+		Claims:   map[string]interface{}{"email": email, "email_verified": true},
+	}, nil
+}
+
+func TestVerifyIDTokenInterceptor(t *testing.T) {
+	verifier, err := util.NewIDTokenVerifier(context.Background(), "https://example.a.run.app",
+		util.WithAllowedEmails("allowed@sample-project.iam.gserviceaccount.com"),
+		util.WithValidator(fakeValidator{
+			"allowed-token": "allowed@sample-project.iam.gserviceaccount.com",
+			"other-token":   "other@sample-project.iam.gserviceaccount.com",
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		authorization string
+		wantCode      codes.Code
+	}{
+		{authorization: "Bearer allowed-token", wantCode: codes.OK},
+		{authorization: "Bearer other-token", wantCode: codes.PermissionDenied},
+		{authorization: "Bearer invalid-token", wantCode: codes.Unauthenticated},
+		{authorization: "", wantCode: codes.Unauthenticated},
+	}
+
+	unaryInfo := &grpc.UnaryServerInfo{FullMethod: "TestService.UnaryMethod"}
+	unaryHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return util.IDTokenPayloadFromContext(ctx).Claims["email"], nil
+	}
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "TestService.StreamMethod"}
+	var streamEmail interface{}
+	streamHandler := func(srv interface{}, stream grpc.ServerStream) error {
+		streamEmail = util.IDTokenPayloadFromContext(stream.Context()).Claims["email"]
+		return nil
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.authorization))
+		}
+
+		resp, err := VerifyIDTokenInterceptor(verifier)(ctx, "xyz", unaryInfo, unaryHandler)
+		if want, got := tt.wantCode, status.Code(err); want != got {
+			t.Errorf("%q: want %v, got %v", tt.authorization, want, got)
+		}
+		if err == nil && resp != "allowed@sample-project.iam.gserviceaccount.com" {
+			t.Errorf("%q: wrong email %v", tt.authorization, resp)
+		}
+
+		streamEmail = nil
+		err = StreamVerifyIDTokenInterceptor(verifier)(nil, &testServerStream{ctx: ctx}, streamInfo, streamHandler)
+		if want, got := tt.wantCode, status.Code(err); want != got {
+			t.Errorf("%q: stream: want %v, got %v", tt.authorization, want, got)
+		}
+		if err == nil && streamEmail != "allowed@sample-project.iam.gserviceaccount.com" {
+			t.Errorf("%q: stream: wrong email %v", tt.authorization, streamEmail)
+		}
+	}
+}
@@ -4,14 +4,30 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"net"
 
 	"github.com/allabout/cloud-run-sdk/util"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
-// addr 127.0.0.1:443
+// NewTLSConn dials the Cloud Run service at addr, e.g. xxx.a.run.app:443.
+// The ID token is attached to each RPC including streams, and refreshed before it expires,
+// so the connection can be kept for the lifetime of the instance.
+// Outside Cloud Run, the token is issued by util.GetIdentityProvider, and no token is attached with util.InsecureIdentityProvider.
+// The connection is always encrypted except for loopback addresses, e.g. localhost:8080, which are dialed as NewInsecureConn.
 func NewTLSConn(ctx context.Context, addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	if isLoopback(addr) {
+		return NewInsecureConn(ctx, addr, opts...)
+	}
+
+	// the trace context of the caller is propagated to both unary and streaming RPCs
+	opts = append([]grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(TraceIDInterceptor),
+		grpc.WithChainStreamInterceptor(StreamTraceIDInterceptor)},
+		opts...,
+	)
+
 	systemRoots, err := x509.SystemCertPool()
 	if err != nil {
 		return nil, err
@@ -21,17 +37,71 @@ func NewTLSConn(ctx context.Context, addr string, opts ...grpc.DialOption) (*grp
 		RootCAs: systemRoots,
 	})
 
-	idToken, err := util.GetIDToken(addr)
-	if err != nil {
+	opts = append([]grpc.DialOption{
+		grpc.WithAuthority(addr),
+		grpc.WithTransportCredentials(cred)},
+		opts...,
+	)
+
+	provider := util.GetIdentityProvider()
+	if util.IsInsecure(provider) {
+		return grpc.DialContext(ctx, addr, opts...)
+	}
+
+	tokenSource := util.NewIDTokenSourceWithProvider(util.AudienceFromAddr(addr), provider)
+	// fails fast if the token isn't available, e.g. not running on Google Cloud
+	if _, err := tokenSource.Token(ctx); err != nil {
 		return nil, err
 	}
 
+	return grpc.DialContext(ctx, addr, append([]grpc.DialOption{grpc.WithPerRPCCredentials(IDTokenCredentials(tokenSource))}, opts...)...)
+}
+
+// NewInsecureConn dials addr without TLS and ID token, e.g. the local server or the sidecar.
+// It must be opted in explicitly, since the requests are sent in plaintext.
+func NewInsecureConn(ctx context.Context, addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	opts = append([]grpc.DialOption{
-		grpc.WithAuthority(addr),
-		grpc.WithTransportCredentials(cred),
-		grpc.WithUnaryInterceptor(AuthInterceptor(idToken))},
+		grpc.WithInsecure(),
+		grpc.WithChainUnaryInterceptor(TraceIDInterceptor),
+		grpc.WithChainStreamInterceptor(StreamTraceIDInterceptor)},
 		opts...,
 	)
 
 	return grpc.DialContext(ctx, addr, opts...)
 }
+
+// isLoopback reports whether the host of addr is localhost or a loopback IP address.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+type idTokenCredentials struct {
+	tokenSource *util.IDTokenSource
+}
+
+// IDTokenCredentials attaches the ID token of tokenSource to each RPC as the bearer token.
+func IDTokenCredentials(tokenSource *util.IDTokenSource) credentials.PerRPCCredentials {
+	return &idTokenCredentials{tokenSource: tokenSource}
+}
+
+func (c *idTokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.tokenSource.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (c *idTokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
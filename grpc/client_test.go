@@ -0,0 +1,233 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"github.com/allabout/cloud-run-sdk/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newFakeMetadataServer serves ID tokens as the metadata server, and returns the counter of the issued tokens.
+func newFakeMetadataServer(t *testing.T) *int {
+	var issued int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/identity" {
+			http.NotFound(w, r)
+			return
+		}
+		issued++
+
+		payload := fmt.Sprintf(`{"aud":%q,"exp":%d}`, r.URL.Query().Get("audience"), time.Now().Add(time.Hour).Unix())
+		fmt.Fprintf(w, "header.%s.signature", base64.RawURLEncoding.EncodeToString([]byte(payload)))
+	}))
+	t.Cleanup(srv.Close)
+
+	old, isSet := os.LookupEnv("GCE_METADATA_HOST")
+	os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(srv.URL, "http://"))
+	t.Cleanup(func() {
+		if isSet {
+			os.Setenv("GCE_METADATA_HOST", old)
+		} else {
+			os.Unsetenv("GCE_METADATA_HOST")
+		}
+	})
+
+	return &issued
+}
+
+func TestIDTokenCredentials(t *testing.T) {
+	issued := newFakeMetadataServer(t)
+
+	creds := IDTokenCredentials(util.NewIDTokenSource("https://example.a.run.app"))
+	if !creds.RequireTransportSecurity() {
+		t.Error("ID token must be sent over TLS")
+	}
+
+	for i := 0; i < 2; i++ {
+		md, err := creds.GetRequestMetadata(context.Background(), "https://example.a.run.app")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		token := strings.TrimPrefix(md["authorization"], "Bearer ")
+		if token == md["authorization"] {
+			t.Fatalf("authorization is not bearer token: %q", md["authorization"])
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := `"aud":"https://example.a.run.app"`; !strings.Contains(string(payload), want) {
+			t.Errorf("want %s in %s", want, payload)
+		}
+	}
+
+	// the token is reused until it expires
+	if want, got := 1, *issued; want != got {
+		t.Errorf("want %d tokens issued, got %d", want, got)
+	}
+}
+
+// startServer serves s on lis until the test ends.
+func startServer(t *testing.T, s *Server, lis net.Listener) {
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		s.Start(lis, stopCh)
+		close(doneCh)
+	}()
+	t.Cleanup(func() {
+		close(stopCh)
+		<-doneCh
+	})
+}
+
+func TestNewInsecureConn(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	traceparentCh := make(chan []string, 1)
+	captureTrace := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		traceparentCh <- md.Get("traceparent")
+		return handler(srv, ss)
+	}
+
+	lis := bufconn.Listen(buffsize)
+	startServer(t, NewServer("google-sample-project", WithStreamInterceptors(captureTrace)), lis)
+
+	dial := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	// the local server is called without TLS and ID token
+	ctx := context.Background()
+	conn, err := NewInsecureConn(ctx, "bufnet", grpc.WithContextDialer(dial))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := healthpb.HealthCheckResponse_SERVING, resp.Status; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// the trace context is propagated to streaming RPCs
+	sc := &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "000000000000007b", Sampled: true}
+	streamCtx, cancel := context.WithCancel(util.ContextWithSpanContext(ctx, sc))
+	defer cancel()
+
+	stream, err := healthpb.NewHealthClient(conn).Watch(streamCtx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := "00-0af7651916cd43dd8448eb211c80319c-", <-traceparentCh; len(got) != 1 || !strings.HasPrefix(got[0], want) {
+		t.Errorf("want traceparent of trace %q, got %q", want, got)
+	}
+}
+
+func TestNewTLSConnWithInsecureProvider(t *testing.T) {
+	util.SetIdentityProvider(util.InsecureIdentityProvider{})
+	defer util.SetIdentityProvider(nil)
+
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	lis := bufconn.Listen(buffsize)
+	startServer(t, NewServer("google-sample-project"), lis)
+
+	dial := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	// TLS is kept without ID token, so the server without TLS can't be called
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	conn, err := NewTLSConn(ctx, "bufnet", grpc.WithContextDialer(dial))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("want %v, got %v", codes.Unavailable, err)
+	}
+}
+
+func TestNewTLSConnLoopback(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	startServer(t, NewServer("google-sample-project"), lis)
+
+	// the loopback address is dialed without TLS and ID token
+	ctx := context.Background()
+	conn, err := NewTLSConn(ctx, lis.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := healthpb.HealthCheckResponse_SERVING, resp.Status; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"localhost:8080":        true,
+		"127.0.0.1:8080":        true,
+		"[::1]:8080":            true,
+		"localhost":             true,
+		"example.a.run.app:443": false,
+		"10.0.0.1:8080":         false,
+		"bufnet":                false,
+	} {
+		if got := isLoopback(addr); got != want {
+			t.Errorf("isLoopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestIDTokenCredentialsWithStaticProvider(t *testing.T) {
+	creds := IDTokenCredentials(util.NewIDTokenSourceWithProvider("https://example.a.run.app", util.StaticIdentityProvider("static-token")))
+
+	md, err := creds.GetRequestMetadata(context.Background(), "https://example.a.run.app")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := "Bearer static-token", md["authorization"]; want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
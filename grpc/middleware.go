@@ -2,51 +2,233 @@ package grpc
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"sync"
 
 	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"github.com/allabout/cloud-run-sdk/tracing"
 	"github.com/allabout/cloud-run-sdk/util"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func LoggerInterceptor(projectID string) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		sharedLogger := zerolog.GetSharedLogger()
-		logger := zerolog.NewLogger(sharedLogger)
+		ctx, span := injectLogger(ctx, projectID, info.FullMethod)
 
-		logger.AddMethod(info.FullMethod)
+		resp, err := handler(ctx, req)
+		tracing.EndGRPC(span, err)
 
-		if !util.IsCloudRun() {
-			return handler(logger.WithContext(ctx), req)
-		}
+		return resp, err
+	}
+}
 
-		if traceID := util.GetTraceIDFromMetadata(ctx); traceID != "" {
-			logger.AddTraceID(projectID, traceID)
-		}
+// StreamLoggerInterceptor is the streaming counterpart of LoggerInterceptor.
+func StreamLoggerInterceptor(projectID string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := injectLogger(ss.Context(), projectID, info.FullMethod)
+
+		err := handler(srv, &wrappedStream{ss, ctx})
+		tracing.EndGRPC(span, err)
+
+		return err
+	}
+}
 
-		return handler(logger.WithContext(ctx), req)
+// injectLogger returns the context having the logger and the server span, which must be ended by the caller.
+// The span is recorded whenever tracing is set up, which is the root span without the trace header.
+func injectLogger(ctx context.Context, projectID, fullMethod string) (context.Context, trace.Span) {
+	sharedLogger := zerolog.GetSharedLogger()
+	logger := zerolog.NewLogger(sharedLogger)
+
+	logger.AddMethod(fullMethod)
+
+	// the trace header is set by Cloud Run, and traceparent is preferred to x-cloud-trace-context
+	var sc *util.SpanContext
+	if util.IsCloudRun() {
+		sc = util.GetSpanContextFromMetadata(ctx)
+	}
+
+	ctx, span, sc := tracing.Start(ctx, strings.TrimPrefix(fullMethod, "/"), sc,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(tracing.GRPCAttributes(fullMethod)...),
+	)
+
+	if sc != nil {
+		logger.AddSpanContext(projectID, sc)
+		ctx = util.ContextWithSpanContext(ctx, sc)
 	}
+
+	return logger.WithContext(ctx), span
+}
+
+// wrappedStream overrides the context of grpc.ServerStream,
+// because grpc.ServerStream doesn't provide a way to replace it.
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
 }
 
 func AuthInterceptor(idToken string) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req interface{}, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		md := metadata.New(map[string]string{"authorization": fmt.Sprintf("Bearer %s", idToken)})
-		ctx = metadata.NewOutgoingContext(ctx, md)
+		return invoker(outgoingAuthContext(ctx, idToken), method, req, reply, cc, opts...)
+	}
+}
 
-		return invoker(ctx, method, req, reply, cc, opts...)
+// StreamAuthInterceptor is the streaming counterpart of AuthInterceptor.
+func StreamAuthInterceptor(idToken string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingAuthContext(ctx, idToken), desc, cc, method, opts...)
 	}
 }
 
+// appends rather than replaces so that metadata added by other interceptors such as the trace context is kept
+func outgoingAuthContext(ctx context.Context, idToken string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", idToken))
+}
+
+// TraceIDInterceptor propagates the trace context of ctx by both x-cloud-trace-context and traceparent metadata.
+// When tracing is set up, a client span is recorded and propagated as the parent of the callee.
+// RPCs without trace context, e.g. called outside requests, are sent as they are.
 func TraceIDInterceptor(ctx context.Context, method string, req interface{}, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-	traceID, ok := ctx.Value("x-cloud-trace-context").(string)
+	sc := spanContextFromContext(ctx)
+	if sc == nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	ctx, span, sc := startClientSpan(ctx, method, sc)
+
+	err := invoker(outgoingTraceContext(ctx, sc), method, req, reply, cc, opts...)
+	tracing.EndGRPC(span, err)
+
+	return err
+}
+
+// StreamTraceIDInterceptor is the streaming counterpart of TraceIDInterceptor.
+// The client span ends when the stream finishes, i.e. RecvMsg returns an error including io.EOF,
+// RecvMsg receives the response of client-streaming RPCs, CloseSend or Header fails, or ctx is done.
+func StreamTraceIDInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	sc := spanContextFromContext(ctx)
+	if sc == nil {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+
+	ctx, span, sc := startClientSpan(ctx, method, sc)
+
+	cs, err := streamer(outgoingTraceContext(ctx, sc), desc, cc, method, opts...)
+	if err != nil {
+		tracing.EndGRPC(span, err)
+		return nil, err
+	}
+
+	return newTracedClientStream(ctx, cs, desc, span), nil
+}
+
+func spanContextFromContext(ctx context.Context) *util.SpanContext {
+	if sc := util.SpanContextFromContext(ctx); sc != nil {
+		return sc
+	}
+
+	// the raw header stored by older versions of http.InjectLogger
+	traceHeader, ok := ctx.Value("x-cloud-trace-context").(string)
 	if !ok {
-		return errors.New("traceID not found")
+		return nil
 	}
+	return util.GetSpanContextFromHeader(traceHeader)
+}
 
-	md := metadata.New(map[string]string{"x-cloud-trace-context": traceID})
-	ctx = metadata.NewOutgoingContext(ctx, md)
+func startClientSpan(ctx context.Context, method string, sc *util.SpanContext) (context.Context, trace.Span, *util.SpanContext) {
+	return tracing.Start(ctx, strings.TrimPrefix(method, "/"), sc,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(tracing.GRPCAttributes(method)...),
+	)
+}
+
+// tracedClientStream ends the client span when the stream finishes.
+type tracedClientStream struct {
+	grpc.ClientStream
+	desc *grpc.StreamDesc
+	span trace.Span
+	once sync.Once
+	// done is closed when the span ends
+	done chan struct{}
+}
+
+func newTracedClientStream(ctx context.Context, cs grpc.ClientStream, desc *grpc.StreamDesc, span trace.Span) *tracedClientStream {
+	s := &tracedClientStream{ClientStream: cs, desc: desc, span: span, done: make(chan struct{})}
+
+	// the stream may be abandoned before it finishes, which is cancelled with ctx
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.end(status.FromContextError(ctx.Err()).Err())
+		case <-s.done:
+		}
+	}()
+
+	return s
+}
+
+func (s *tracedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	case !s.desc.ServerStreams:
+		// the only response of client-streaming RPCs, e.g. received by CloseAndRecv
+		s.end(nil)
+	}
+	return err
+}
+
+func (s *tracedClientStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *tracedClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+	return md, err
+}
+
+func (s *tracedClientStream) end(err error) {
+	s.once.Do(func() {
+		tracing.EndGRPC(s.span, err)
+		close(s.done)
+	})
+}
+
+func outgoingTraceContext(ctx context.Context, sc *util.SpanContext) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+
+	// replaces only the trace keys, e.g. forwarded by gateway, so that the callee finds a single trace context
+	// and metadata added by other interceptors such as authorization is kept
+	md.Set("x-cloud-trace-context", sc.CloudTraceContext())
+	delete(md, "traceparent")
+	delete(md, "tracestate")
+	if traceparent := sc.Traceparent(); traceparent != "" {
+		md.Set("traceparent", traceparent)
+		if sc.TraceState != "" {
+			md.Set("tracestate", sc.TraceState)
+		}
+	}
 
-	return invoker(ctx, method, req, reply, cc, opts...)
+	return metadata.NewOutgoingContext(ctx, md)
 }
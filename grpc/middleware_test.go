@@ -3,10 +3,22 @@ package grpc
 import (
 	"bytes"
 	"context"
+	"io"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"github.com/allabout/cloud-run-sdk/util"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestLoggerInterceptor(t *testing.T) {
@@ -35,3 +47,400 @@ func TestLoggerInterceptor(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamLoggerInterceptor(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	expected := `{"severity":"INFO","method":"TestService.StreamMethod","logging.googleapis.com/trace":"projects/google-sample-project/traces/0123456789abcdef0123456789abcdef","logging.googleapis.com/spanId":"000000000000007b","logging.googleapis.com/trace_sampled":true,"message":"message"}` + "\n"
+
+	streamInfo := &grpc.StreamServerInfo{
+		FullMethod:     "TestService.StreamMethod",
+		IsServerStream: true,
+	}
+
+	streamHandler := func(srv interface{}, stream grpc.ServerStream) error {
+		zerolog.Ctx(stream.Context()).Info("message")
+
+		if want, got := expected, string(buf.Bytes()); got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+
+		return nil
+	}
+
+	md := metadata.New(map[string]string{"x-cloud-trace-context": "0123456789abcdef0123456789abcdef/123;o=1"})
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	err := StreamLoggerInterceptor("google-sample-project")(nil, &testServerStream{ctx: ctx}, streamInfo, streamHandler)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTraceIDInterceptor(t *testing.T) {
+	sc := &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "000000000000007b", Sampled: true, TraceState: "congo=t61rcWkgMzE"}
+	ctx := util.ContextWithSpanContext(context.Background(), sc)
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer token")
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+
+		for key, want := range map[string]string{
+			"authorization":         "Bearer token",
+			"x-cloud-trace-context": "0af7651916cd43dd8448eb211c80319c/123;o=1",
+			"traceparent":           "00-0af7651916cd43dd8448eb211c80319c-000000000000007b-01",
+			"tracestate":            "congo=t61rcWkgMzE",
+		} {
+			if got := md.Get(key); len(got) != 1 || got[0] != want {
+				t.Errorf("%s: want %q, got %q", key, want, got)
+			}
+		}
+
+		return nil
+	}
+
+	if err := TraceIDInterceptor(ctx, "TestService.UnaryMethod", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoggerInterceptorWithTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	unaryInfo := &grpc.UnaryServerInfo{
+		FullMethod: "/TestService/UnaryMethod",
+	}
+
+	var clientSpanID string
+	unaryHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			clientSpanID = util.GetSpanContextFromTraceparent(md.Get("traceparent")[0], "").SpanID
+			return nil
+		}
+		// the client span is a child of the server span
+		if err := TraceIDInterceptor(ctx, "/TestService/Downstream", nil, nil, nil, invoker); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"))
+	if _, err := LoggerInterceptor("google-sample-project")(ctx, "xyz", unaryInfo, unaryHandler); status.Code(err) != codes.NotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("wrong number of spans %d, want 2", len(spans))
+	}
+	client, server := spans[0], spans[1]
+
+	for _, tt := range []struct {
+		name     string
+		got      interface{}
+		expected interface{}
+	}{
+		{"server name", server.Name, "TestService/UnaryMethod"},
+		{"server kind", server.SpanKind, trace.SpanKindServer},
+		{"server parent", server.Parent.SpanID().String(), "b7ad6b7169203331"},
+		{"server status", server.Status.Code, otelcodes.Error},
+		{"client name", client.Name, "TestService/Downstream"},
+		{"client kind", client.SpanKind, trace.SpanKindClient},
+		{"client parent", client.Parent.SpanID(), server.SpanContext.SpanID()},
+		{"propagated span", clientSpanID, client.SpanContext.SpanID().String()},
+	} {
+		if tt.got != tt.expected {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.expected, tt.got)
+		}
+	}
+}
+
+func TestLoggerInterceptorWithTracingOutsideCloudRun(t *testing.T) {
+	if err := os.Unsetenv("K_CONFIGURATION"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("K_CONFIGURATION", "true")
+
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	unaryInfo := &grpc.UnaryServerInfo{
+		FullMethod: "/TestService/UnaryMethod",
+	}
+
+	var sc *util.SpanContext
+	unaryHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		sc = util.SpanContextFromContext(ctx)
+		return nil, nil
+	}
+
+	// the request without trace header
+	if _, err := LoggerInterceptor("google-sample-project")(context.Background(), "xyz", unaryInfo, unaryHandler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("wrong number of spans %d, want 1", len(spans))
+	}
+	server := spans[0]
+
+	for _, tt := range []struct {
+		name     string
+		got      interface{}
+		expected interface{}
+	}{
+		{"server name", server.Name, "TestService/UnaryMethod"},
+		{"server kind", server.SpanKind, trace.SpanKindServer},
+		{"server parent", server.Parent.IsValid(), false},
+		{"span in context", sc != nil && sc.SpanID == server.SpanContext.SpanID().String(), true},
+	} {
+		if tt.got != tt.expected {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.expected, tt.got)
+		}
+	}
+}
+
+func TestTraceIDInterceptorWithoutTrace(t *testing.T) {
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if md, ok := metadata.FromOutgoingContext(ctx); ok {
+			t.Errorf("want no metadata, got %v", md)
+		}
+		return nil
+	}
+
+	if err := TraceIDInterceptor(context.Background(), "TestService.UnaryMethod", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type testClientStream struct {
+	grpc.ClientStream
+	recvErr      error
+	closeSendErr error
+	headerErr    error
+}
+
+func (s *testClientStream) RecvMsg(m interface{}) error {
+	return s.recvErr
+}
+
+func (s *testClientStream) CloseSend() error {
+	return s.closeSendErr
+}
+
+func (s *testClientStream) Header() (metadata.MD, error) {
+	return nil, s.headerErr
+}
+
+func TestStreamAuthInterceptor(t *testing.T) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-0af7651916cd43dd8448eb211c80319c-000000000000007b-01")
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+
+		for key, want := range map[string]string{
+			"authorization": "Bearer token",
+			"traceparent":   "00-0af7651916cd43dd8448eb211c80319c-000000000000007b-01",
+		} {
+			if got := md.Get(key); len(got) != 1 || got[0] != want {
+				t.Errorf("%s: want %q, got %q", key, want, got)
+			}
+		}
+
+		return &testClientStream{}, nil
+	}
+
+	if _, err := StreamAuthInterceptor("token")(ctx, &grpc.StreamDesc{}, nil, "TestService.StreamMethod", streamer); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStreamTraceIDInterceptor(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	sc := &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "000000000000007b", Sampled: true}
+
+	for _, tt := range []struct {
+		ctx        context.Context
+		recvErr    error
+		wantTrace  bool
+		wantStatus otelcodes.Code
+	}{
+		{util.ContextWithSpanContext(context.Background(), sc), io.EOF, true, otelcodes.Unset},
+		{util.ContextWithSpanContext(context.Background(), sc), status.Error(codes.Unavailable, "unavailable"), true, otelcodes.Error},
+		{context.Background(), io.EOF, false, otelcodes.Unset},
+	} {
+		exporter.Reset()
+
+		var traceparent []string
+		streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			traceparent = md.Get("traceparent")
+			return &testClientStream{recvErr: tt.recvErr}, nil
+		}
+
+		cs, err := StreamTraceIDInterceptor(tt.ctx, &grpc.StreamDesc{ServerStreams: true}, nil, "/TestService/StreamMethod", streamer)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !tt.wantTrace {
+			if len(traceparent) != 0 {
+				t.Errorf("want no traceparent, got %q", traceparent)
+			}
+			continue
+		}
+
+		// the span is ended when the stream finishes
+		if spans := exporter.GetSpans(); len(spans) != 0 {
+			t.Fatalf("span is ended before the stream finishes")
+		}
+		if err := cs.RecvMsg(nil); err != tt.recvErr {
+			t.Fatalf("want %v, got %v", tt.recvErr, err)
+		}
+		cs.RecvMsg(nil)
+
+		spans := exporter.GetSpans()
+		if len(spans) != 1 {
+			t.Fatalf("wrong number of spans %d, want 1", len(spans))
+		}
+		if want, got := tt.wantStatus, spans[0].Status.Code; want != got {
+			t.Errorf("want %v, got %v", want, got)
+		}
+		if want := "00-0af7651916cd43dd8448eb211c80319c-" + spans[0].SpanContext.SpanID().String() + "-01"; len(traceparent) != 1 || traceparent[0] != want {
+			t.Errorf("want %q, got %q", want, traceparent)
+		}
+	}
+}
+
+func TestStreamTraceIDInterceptorEndsSpan(t *testing.T) {
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	sc := &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "000000000000007b", Sampled: true}
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	for _, tt := range []struct {
+		name       string
+		desc       *grpc.StreamDesc
+		stream     *testClientStream
+		finish     func(cs grpc.ClientStream, cancel context.CancelFunc)
+		wantEnded  bool
+		wantStatus otelcodes.Code
+	}{
+		{
+			name:   "client streaming",
+			desc:   &grpc.StreamDesc{ClientStreams: true},
+			stream: &testClientStream{},
+			finish: func(cs grpc.ClientStream, cancel context.CancelFunc) {
+				// CloseAndRecv
+				cs.CloseSend()
+				cs.RecvMsg(nil)
+			},
+			wantEnded:  true,
+			wantStatus: otelcodes.Unset,
+		},
+		{
+			name:   "server streaming",
+			desc:   &grpc.StreamDesc{ServerStreams: true},
+			stream: &testClientStream{},
+			finish: func(cs grpc.ClientStream, cancel context.CancelFunc) {
+				// more responses follow
+				cs.RecvMsg(nil)
+			},
+			wantEnded: false,
+		},
+		{
+			name:   "CloseSend error",
+			desc:   &grpc.StreamDesc{ClientStreams: true, ServerStreams: true},
+			stream: &testClientStream{closeSendErr: unavailable},
+			finish: func(cs grpc.ClientStream, cancel context.CancelFunc) {
+				cs.CloseSend()
+			},
+			wantEnded:  true,
+			wantStatus: otelcodes.Error,
+		},
+		{
+			name:   "Header error",
+			desc:   &grpc.StreamDesc{ServerStreams: true},
+			stream: &testClientStream{headerErr: unavailable},
+			finish: func(cs grpc.ClientStream, cancel context.CancelFunc) {
+				cs.Header()
+			},
+			wantEnded:  true,
+			wantStatus: otelcodes.Error,
+		},
+		{
+			name:   "abandoned",
+			desc:   &grpc.StreamDesc{ServerStreams: true},
+			stream: &testClientStream{},
+			finish: func(cs grpc.ClientStream, cancel context.CancelFunc) {
+				cancel()
+			},
+			wantEnded:  true,
+			wantStatus: otelcodes.Error,
+		},
+	} {
+		// the exporter is not shared, since the span of the previous case may be ended asynchronously
+		exporter := tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+		streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return tt.stream, nil
+		}
+
+		ctx, cancel := context.WithCancel(util.ContextWithSpanContext(context.Background(), sc))
+		cs, err := StreamTraceIDInterceptor(ctx, tt.desc, nil, "/TestService/StreamMethod", streamer)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+
+		tt.finish(cs, cancel)
+
+		// the span may be ended asynchronously when ctx is done
+		var spans []sdktrace.ReadOnlySpan
+		for i := 0; i < 10; i++ {
+			if spans = exporter.GetSpans().Snapshots(); len(spans) != 0 || !tt.wantEnded {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+
+		if !tt.wantEnded {
+			if len(spans) != 0 {
+				t.Errorf("%s: span is ended before the stream finishes", tt.name)
+			}
+			continue
+		}
+		if len(spans) != 1 {
+			t.Fatalf("%s: wrong number of spans %d, want 1", tt.name, len(spans))
+		}
+		if want, got := tt.wantStatus, spans[0].Status().Code; want != got {
+			t.Errorf("%s: want %v, got %v", tt.name, want, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package grpc
+
+import (
+	"google.golang.org/grpc"
+)
+
+type options struct {
+	serverOptions      []grpc.ServerOption
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	reflection         bool
+}
+
+// Option configures Server created by NewServer.
+type Option func(*options)
+
+// WithServerOptions appends grpc.ServerOption such as keepalive, max message sizes or credentials.
+// Interceptors should be passed by WithUnaryInterceptors or WithStreamInterceptors
+// so that they are chained after the SDK's logger interceptors.
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, opts...)
+	}
+}
+
+// WithUnaryInterceptors appends unary interceptors which are chained after LoggerInterceptor,
+// so that the logs written by them such as AccessLogInterceptor are correlated with the trace.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return func(o *options) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors appends stream interceptors which are chained after StreamLoggerInterceptor,
+// so that the logs written by them such as StreamAccessLogInterceptor are correlated with the trace.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) Option {
+	return func(o *options) {
+		o.streamInterceptors = append(o.streamInterceptors, interceptors...)
+	}
+}
+
+// WithReflection enables or disables the server reflection service. It is enabled by default.
+func WithReflection(enabled bool) Option {
+	return func(o *options) {
+		o.reflection = enabled
+	}
+}
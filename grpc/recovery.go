@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RecoveryInterceptor recovers from panics in the handlers and returns codes.Internal to the client.
+// The stack trace is logged at ERROR severity in the format of Go panic, which Cloud Error Reporting picks up.
+func RecoveryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recoverPanic(ctx, p)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecoveryInterceptor is the streaming counterpart of RecoveryInterceptor.
+func StreamRecoveryInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recoverPanic(ss.Context(), p)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+func recoverPanic(ctx context.Context, p interface{}) error {
+	zerolog.Ctx(ctx).Errorf("panic: %v\n\n%s", p, debug.Stack())
+
+	return status.Error(codes.Internal, "internal server error")
+}
@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoveryInterceptor(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	unaryInfo := &grpc.UnaryServerInfo{
+		FullMethod: "TestService.UnaryMethod",
+	}
+
+	unaryHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("something wrong")
+	}
+
+	ctx := zerolog.NewLogger(zerolog.GetSharedLogger()).WithContext(context.Background())
+	_, err := RecoveryInterceptor()(ctx, "xyz", unaryInfo, unaryHandler)
+
+	if want, got := codes.Internal, status.Code(err); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if want := `{"severity":"ERROR","message":"panic: something wrong\n\ngoroutine `; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("want prefix %q, got %q", want, buf.String())
+	}
+}
+
+func TestStreamRecoveryInterceptor(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	streamInfo := &grpc.StreamServerInfo{
+		FullMethod:     "TestService.StreamMethod",
+		IsServerStream: true,
+	}
+
+	streamHandler := func(srv interface{}, stream grpc.ServerStream) error {
+		panic("something wrong")
+	}
+
+	ctx := zerolog.NewLogger(zerolog.GetSharedLogger()).WithContext(context.Background())
+	err := StreamRecoveryInterceptor()(nil, &testServerStream{ctx: ctx}, streamInfo, streamHandler)
+
+	if want, got := codes.Internal, status.Code(err); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if want := `{"severity":"ERROR","message":"panic: something wrong\n\ngoroutine `; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("want prefix %q, got %q", want, buf.String())
+	}
+}
@@ -1,29 +1,74 @@
 package grpc
 
 import (
+	"context"
+	"fmt"
 	"net"
+	"net/http"
 	"os"
 
 	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"github.com/allabout/cloud-run-sdk/util"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
 type Server struct {
-	Srv *grpc.Server
+	Srv    *grpc.Server
+	health *health.Server
 }
 
-func NewServer(projectID string, interceptors ...grpc.UnaryServerInterceptor) *Server {
-	interceptors = append([]grpc.UnaryServerInterceptor{LoggerInterceptor(projectID)}, interceptors...)
+func NewServer(projectID string, opts ...Option) *Server {
+	o := &options{
+		reflection: true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	unaryInterceptors := append([]grpc.UnaryServerInterceptor{LoggerInterceptor(projectID)}, o.unaryInterceptors...)
+	streamInterceptors := append([]grpc.StreamServerInterceptor{StreamLoggerInterceptor(projectID)}, o.streamInterceptors...)
+
+	serverOptions := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}, o.serverOptions...)
+
+	srv := grpc.NewServer(serverOptions...)
+	if o.reflection {
+		reflection.Register(srv)
+	}
 
-	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
-	reflection.Register(srv)
+	// the overall status of the server, which is represented by empty service name, is SERVING by default
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthSrv)
 
 	return &Server{
-		Srv: srv,
+		Srv:    srv,
+		health: healthSrv,
 	}
 }
 
+// SetServingStatus sets the serving status of the service reported by grpc.health.v1.Health.
+// An empty service name represents the overall status of the server.
+func (s *Server) SetServingStatus(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	s.health.SetServingStatus(service, status)
+}
+
+// ServeHTTP serves gRPC requests routed by http.Server.HandleGRPC.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Srv.ServeHTTP(w, r)
+}
+
+// Shutdown sets all services to NOT_SERVING and ignores the further status changes.
+// It is called by http.Server.HandleGRPC before draining connections instead of Run,
+// since GracefulStop panics on the requests served by ServeHTTP.
+func (s *Server) Shutdown() {
+	s.health.Shutdown()
+}
+
 func CreateNetworkListener() (net.Listener, error) {
 	port, isSet := os.LookupEnv("GRPC_PORT")
 	if !isSet {
@@ -38,20 +83,46 @@ func CreateNetworkListener() (net.Listener, error) {
 	return net.Listen("tcp", hostAddr+":"+port)
 }
 
-func (s *Server) Start(lis net.Listener, stopCh <-chan struct{}) {
+// Start serves gRPC until stopCh is closed or the listener fails.
+// It returns nil on clean shutdown, and an error when the listener fails.
+func (s *Server) Start(lis net.Listener, stopCh <-chan struct{}) error {
+	ctx, cancel := util.ContextWithStopCh(stopCh)
+	defer cancel()
+
+	return s.Run(ctx, lis)
+}
+
+// Run is the same as Start except that it serves gRPC until ctx is done.
+func (s *Server) Run(ctx context.Context, lis net.Listener) error {
 	sharedLogger := zerolog.GetSharedLogger()
 
+	errCh := make(chan error, 1)
 	go func() {
+		// Serve returns nil only after Stop or GracefulStop is called
 		if err := s.Srv.Serve(lis); err != nil {
-			sharedLogger.Error().Msgf("server closed with error : %v", err)
+			errCh <- err
 		}
 	}()
 
-	<-stopCh
+	select {
+	case err := <-errCh:
+		sharedLogger.Error().Msgf("server closed with error : %v", err)
+
+		s.health.Shutdown()
+		s.Srv.Stop()
+
+		return fmt.Errorf("server closed with error: %w", err)
+	case <-ctx.Done():
+	}
 
 	sharedLogger.Info().Msg("recive SIGTERM or SIGINT")
 
+	// set all services to NOT_SERVING before draining connections,
+	// so that health checks fail while in-flight RPCs are completed
+	s.health.Shutdown()
 	s.Srv.GracefulStop()
 
 	sharedLogger.Info().Msg("gRPC Server shutdowned")
+
+	return nil
 }
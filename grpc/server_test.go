@@ -9,6 +9,7 @@ import (
 	"github.com/allabout/cloud-run-sdk/logging/zerolog"
 	"github.com/allabout/cloud-run-sdk/util"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/interop"
 	pb "google.golang.org/grpc/interop/grpc_testing"
 	"google.golang.org/grpc/test/bufconn"
@@ -32,7 +33,7 @@ func TestStartServer(t *testing.T) {
 		return handler(ctx, req)
 	}
 
-	s := NewServer("google-sample-project", fn)
+	s := NewServer("google-sample-project", WithUnaryInterceptors(fn))
 	lis := bufconn.Listen(buffsize)
 
 	pb.RegisterTestServiceServer(s.Srv, interop.NewTestServer())
@@ -54,3 +55,113 @@ func TestStartServer(t *testing.T) {
 	client := pb.NewTestServiceClient(conn)
 	interop.DoEmptyUnaryCall(client)
 }
+
+func TestNewServerWithReflection(t *testing.T) {
+	const reflectionService = "grpc.reflection.v1alpha.ServerReflection"
+
+	for _, tt := range []struct {
+		opts []Option
+		want bool
+	}{
+		{nil, true},
+		{[]Option{WithReflection(true)}, true},
+		{[]Option{WithReflection(false)}, false},
+	} {
+		s := NewServer("google-sample-project", tt.opts...)
+
+		if _, got := s.Srv.GetServiceInfo()[reflectionService]; got != tt.want {
+			t.Errorf("want %v, got %v", tt.want, got)
+		}
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	s := NewServer("google-sample-project")
+	s.SetServingStatus("grpc.testing.TestService", healthpb.HealthCheckResponse_NOT_SERVING)
+	lis := bufconn.Listen(buffsize)
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		s.Start(lis, stopCh)
+		close(doneCh)
+	}()
+
+	ctx := context.Background()
+	dial := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	opts := []grpc.DialOption{grpc.WithInsecure(), grpc.WithContextDialer(dial)}
+	conn, err := grpc.DialContext(ctx, "bufnet", opts...)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+
+	for _, tt := range []struct {
+		service string
+		want    healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{"", healthpb.HealthCheckResponse_SERVING},
+		{"grpc.testing.TestService", healthpb.HealthCheckResponse_NOT_SERVING},
+	} {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: tt.service})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want, got := tt.want, resp.Status; want != got {
+			t.Errorf("want %v, got %v", want, got)
+		}
+	}
+
+	close(stopCh)
+	<-doneCh
+
+	resp, err := s.health.Check(ctx, &healthpb.HealthCheckRequest{Service: ""})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := healthpb.HealthCheckResponse_NOT_SERVING, resp.Status; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestStartWithListenerError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	s := NewServer("google-sample-project")
+	lis := bufconn.Listen(buffsize)
+	// Serve fails immediately because of closed listener
+	lis.Close()
+
+	if err := s.Start(lis, make(chan struct{})); err == nil {
+		t.Error("want error, got nil")
+	}
+}
+
+func TestRun(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, true, false)
+
+	s := NewServer("google-sample-project")
+	lis := bufconn.Listen(buffsize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Run(ctx, lis)
+	}()
+
+	cancel()
+
+	if err := <-errCh; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
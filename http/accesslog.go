@@ -0,0 +1,158 @@
+package http
+
+import (
+	"bufio"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+)
+
+type accessLogOptions struct {
+	sampleRate   float64
+	excludePaths map[string]struct{}
+	proxyHops    int
+}
+
+// AccessLogOption configures AccessLog middleware.
+type AccessLogOption func(*accessLogOptions)
+
+// WithSampleRate sets the ratio of requests to be logged in [0, 1]. 5xx errors are always logged.
+func WithSampleRate(rate float64) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.sampleRate = rate
+	}
+}
+
+// WithExcludePaths excludes requests to the paths from the access log, e.g. health checks.
+func WithExcludePaths(paths ...string) AccessLogOption {
+	return func(o *accessLogOptions) {
+		for _, p := range paths {
+			o.excludePaths[p] = struct{}{}
+		}
+	}
+}
+
+// WithProxyHops sets the number of proxies appending X-Forwarded-For in front of the service,
+// e.g. 2 behind an external load balancer. It is 1 by default for Cloud Run.
+func WithProxyHops(n int) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.proxyHops = n
+	}
+}
+
+// AccessLog logs each request in the httpRequest format of Cloud Logging.
+func AccessLog(opts ...AccessLogOption) Middleware {
+	o := &accessLogOptions{
+		sampleRate:   1,
+		excludePaths: map[string]struct{}{},
+		proxyHops:    1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := o.excludePaths[r.URL.Path]; ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+			h.ServeHTTP(rw, r)
+
+			if rw.status < http.StatusInternalServerError && rand.Float64() >= o.sampleRate {
+				return
+			}
+
+			zerolog.Ctx(r.Context()).LogHTTPRequest(&zerolog.HTTPRequest{
+				RequestMethod: r.Method,
+				RequestURL:    requestURL(r),
+				RequestSize:   r.ContentLength,
+				Status:        rw.status,
+				ResponseSize:  rw.size,
+				UserAgent:     r.UserAgent(),
+				RemoteIP:      remoteIP(r, o.proxyHops),
+				Referer:       r.Referer(),
+				Latency:       time.Since(start),
+				Protocol:      r.Proto,
+			})
+		})
+	}
+}
+
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	// Cloud Run terminates TLS and forwards the original scheme
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+func remoteIP(r *http.Request, proxyHops int) string {
+	// Cloud Run appends the client to X-Forwarded-For sent by the client, so the entries before it can be spoofed.
+	// The client is the entry appended by the first of the trusted proxies, which is counted from the last.
+	var entries []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		entries = append(entries, strings.Split(v, ",")...)
+	}
+	if proxyHops > 0 && len(entries) >= proxyHops {
+		return strings.TrimSpace(entries[len(entries)-proxyHops])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// responseWriter records the status code and the size of the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http.Hijacker is not implemented")
+	}
+	return h.Hijack()
+}
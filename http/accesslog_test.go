@@ -0,0 +1,141 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+)
+
+type accessLogEntry struct {
+	Severity    string `json:"severity"`
+	Message     string `json:"message"`
+	HTTPRequest struct {
+		RequestMethod string `json:"requestMethod"`
+		RequestURL    string `json:"requestUrl"`
+		Status        int    `json:"status"`
+		ResponseSize  string `json:"responseSize"`
+		UserAgent     string `json:"userAgent"`
+		RemoteIP      string `json:"remoteIp"`
+		Protocol      string `json:"protocol"`
+	} `json:"httpRequest"`
+}
+
+func TestAccessLog(t *testing.T) {
+	var okFn = func(ctx context.Context) ([]byte, *AppError) {
+		return []byte("ok"), nil
+	}
+
+	var notFoundFn = func(ctx context.Context) ([]byte, *AppError) {
+		return nil, Error(http.StatusNotFound, "not found")
+	}
+
+	tests := []struct {
+		handler     AppHandler
+		path        string
+		opts        []AccessLogOption
+		wantEntries int
+		wantEntry   accessLogEntry
+	}{
+		{
+			handler:     okFn,
+			path:        "/users?id=1",
+			wantEntries: 1,
+			wantEntry: func() accessLogEntry {
+				var e accessLogEntry
+				e.Severity = "INFO"
+				e.Message = "GET https://example.com/users?id=1 200"
+				e.HTTPRequest.RequestMethod = "GET"
+				e.HTTPRequest.RequestURL = "https://example.com/users?id=1"
+				e.HTTPRequest.Status = 200
+				e.HTTPRequest.ResponseSize = "2"
+				e.HTTPRequest.UserAgent = "test-agent"
+				e.HTTPRequest.RemoteIP = "203.0.113.1"
+				e.HTTPRequest.Protocol = "HTTP/1.1"
+				return e
+			}(),
+		},
+		{
+			handler:     notFoundFn,
+			path:        "/users",
+			wantEntries: 2, // the warning of AppHandler and the access log
+		},
+		{
+			handler:     okFn,
+			path:        "/healthz",
+			opts:        []AccessLogOption{WithExcludePaths("/healthz")},
+			wantEntries: 0,
+		},
+		{
+			handler:     okFn,
+			path:        "/users",
+			opts:        []AccessLogOption{WithSampleRate(0)},
+			wantEntries: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		zerolog.SetSharedLogger(buf, false, false)
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+		req.Header.Set("User-Agent", "test-agent")
+		// the first entry is sent by the client, and the last one is appended by Cloud Run
+		req.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.1")
+		req.Header.Set("X-Forwarded-Proto", "https")
+
+		Chain(tt.handler, InjectLogger("google-sample-project"), AccessLog(tt.opts...)).ServeHTTP(httptest.NewRecorder(), req)
+
+		lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+		if buf.Len() == 0 {
+			lines = nil
+		}
+		if want, got := tt.wantEntries, len(lines); want != got {
+			t.Fatalf("want %d entries, got %d: %q", want, got, buf.String())
+		}
+
+		if tt.wantEntry.Severity == "" {
+			continue
+		}
+
+		var entry accessLogEntry
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if want, got := tt.wantEntry, entry; !reflect.DeepEqual(want, got) {
+			t.Errorf("wrong entry %#v, want %#v", got, want)
+		}
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	for _, tt := range []struct {
+		xff       []string
+		proxyHops int
+		want      string
+	}{
+		{xff: []string{"203.0.113.1"}, proxyHops: 1, want: "203.0.113.1"},
+		{xff: []string{"198.51.100.1, 203.0.113.1"}, proxyHops: 1, want: "203.0.113.1"},
+		{xff: []string{"198.51.100.1", "203.0.113.1"}, proxyHops: 1, want: "203.0.113.1"},
+		{xff: []string{"198.51.100.1, 203.0.113.1, 35.191.0.1"}, proxyHops: 2, want: "203.0.113.1"},
+		// fewer entries than the proxies, so it's not trusted
+		{xff: []string{"203.0.113.1"}, proxyHops: 2, want: "192.0.2.1"},
+		{xff: nil, proxyHops: 1, want: "192.0.2.1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, v := range tt.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+
+		if got := remoteIP(req, tt.proxyHops); got != tt.want {
+			t.Errorf("remoteIP(%q, %d) = %q, want %q", tt.xff, tt.proxyHops, got, tt.want)
+		}
+	}
+}
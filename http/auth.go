@@ -0,0 +1,43 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"github.com/allabout/cloud-run-sdk/util"
+)
+
+// VerifyIDToken verifies the Google-signed ID token in Authorization header, and puts the claims into the request context,
+// which can be read by util.IDTokenPayloadFromContext.
+// It responds 401 if the token is invalid, and 403 if the caller is not allowed.
+func VerifyIDToken(verifier *util.IDTokenVerifier) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			payload, err := verifier.VerifyAuthorization(ctx, r.Header.Get("Authorization"))
+			if err != nil {
+				zerolog.Ctx(ctx).Warnf("failed to verify ID token : %v", err)
+
+				code := http.StatusUnauthorized
+				if errors.Is(err, util.ErrEmailNotAllowed) {
+					code = http.StatusForbidden
+				} else {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+				}
+
+				// the detail of the error is hidden from the client
+				appErr := Error(code, http.StatusText(code))
+				w.WriteHeader(appErr.Code)
+				if err := json.NewEncoder(w).Encode(appErr); err != nil {
+					zerolog.Ctx(ctx).Error(err)
+				}
+				return
+			}
+
+			h.ServeHTTP(w, r.WithContext(util.ContextWithIDTokenPayload(ctx, payload)))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/allabout/cloud-run-sdk/util"
+	"google.golang.org/api/idtoken"
+)
+
+// fakeValidator accepts the tokens of the keys as the ID tokens of the emails.
+type fakeValidator map[string]string
+
+func (v fakeValidator) Validate(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+	email, ok := v[token]
+	if !ok {
+		return nil, errors.New("invalid signature")
+	}
+	return &idtoken.Payload{
+		Issuer:   "https://accounts.google.com",
+		Audience: audience,
+		Claims:   map[string]interface{}{"email": email, "email_verified": true},
+	}, nil
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	verifier, err := util.NewIDTokenVerifier(context.Background(), "https://example.a.run.app",
+		util.WithAllowedEmails("allowed@sample-project.iam.gserviceaccount.com"),
+		util.WithValidator(fakeValidator{
+			"allowed-token": "allowed@sample-project.iam.gserviceaccount.com",
+			"other-token":   "other@sample-project.iam.gserviceaccount.com",
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		authorization string
+		wantCode      int
+		wantBody      string
+	}{
+		{authorization: "Bearer allowed-token", wantCode: http.StatusOK, wantBody: "allowed@sample-project.iam.gserviceaccount.com"},
+		{authorization: "Bearer other-token", wantCode: http.StatusForbidden, wantBody: `{"code":403,"message":"Forbidden"}` + "\n"},
+		{authorization: "Bearer invalid-token", wantCode: http.StatusUnauthorized, wantBody: `{"code":401,"message":"Unauthorized"}` + "\n"},
+		{authorization: "", wantCode: http.StatusUnauthorized, wantBody: `{"code":401,"message":"Unauthorized"}` + "\n"},
+	}
+
+	var appHandler AppHandler = func(ctx context.Context) ([]byte, *AppError) {
+		payload := util.IDTokenPayloadFromContext(ctx)
+		return []byte(payload.Claims["email"].(string)), nil
+	}
+	handler := Chain(appHandler, InjectLogger("sample-google-project"), VerifyIDToken(verifier))
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if want, got := tt.wantCode, rec.Code; want != got {
+			t.Errorf("%q: wrong status %d, want %d", tt.authorization, got, want)
+		}
+		if want, got := tt.wantBody, rec.Body.String(); want != got {
+			t.Errorf("%q: wrong body %q, want %q", tt.authorization, got, want)
+		}
+	}
+}
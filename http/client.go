@@ -0,0 +1,58 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/allabout/cloud-run-sdk/util"
+)
+
+// NewAuthenticatedClient creates http.Client calling the Cloud Run service of audience, e.g. https://xxx.a.run.app.
+// The ID token of the service account is attached to each request and refreshed before it expires,
+// and the trace context of the request is propagated as TraceTransport does.
+// The ID token is issued by util.GetIdentityProvider, e.g. the metadata server on Cloud Run,
+// which can be replaced by GCE_METADATA_HOST environment variable in tests.
+func NewAuthenticatedClient(audience string) *http.Client {
+	return &http.Client{
+		Transport: &IDTokenTransport{
+			TokenSource: util.NewIDTokenSource(audience),
+			Base:        &TraceTransport{},
+		},
+	}
+}
+
+// IDTokenTransport attaches the ID token of TokenSource to outgoing requests as the bearer token.
+type IDTokenTransport struct {
+	TokenSource *util.IDTokenSource
+	// Base is the RoundTripper to send requests. http.DefaultTransport is used if nil.
+	Base http.RoundTripper
+}
+
+func (t *IDTokenTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *IDTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.TokenSource.Token(req.Context())
+	if err != nil {
+		// RoundTripper must close the body even on errors
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("failed to get ID token: %w", err)
+	}
+
+	// util.InsecureIdentityProvider doesn't issue tokens
+	if token == "" {
+		return t.base().RoundTrip(req)
+	}
+
+	// RoundTripper must not modify the original request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return t.base().RoundTrip(req)
+}
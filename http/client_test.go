@@ -0,0 +1,126 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allabout/cloud-run-sdk/util"
+)
+
+// newFakeMetadataServer serves ID tokens as the metadata server, and returns the counter of the issued tokens.
+// When fail is true, it responds 404 to all requests.
+func newFakeMetadataServer(t *testing.T, fail bool) *int {
+	var issued int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail || r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/identity" {
+			http.NotFound(w, r)
+			return
+		}
+		issued++
+
+		payload := fmt.Sprintf(`{"aud":%q,"exp":%d}`, r.URL.Query().Get("audience"), time.Now().Add(time.Hour).Unix())
+		fmt.Fprintf(w, "header.%s.signature", base64.RawURLEncoding.EncodeToString([]byte(payload)))
+	}))
+	t.Cleanup(srv.Close)
+
+	old, isSet := os.LookupEnv("GCE_METADATA_HOST")
+	os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(srv.URL, "http://"))
+	t.Cleanup(func() {
+		if isSet {
+			os.Setenv("GCE_METADATA_HOST", old)
+		} else {
+			os.Unsetenv("GCE_METADATA_HOST")
+		}
+	})
+
+	return &issued
+}
+
+func TestNewAuthenticatedClient(t *testing.T) {
+	issued := newFakeMetadataServer(t, false)
+	srv, headerCh := newHeaderServer(t)
+
+	client := NewAuthenticatedClient("https://example.a.run.app")
+
+	sc := &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true}
+	req, err := http.NewRequestWithContext(util.ContextWithSpanContext(context.Background(), sc), "GET", srv.URL, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+
+		header := <-headerCh
+
+		token := strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+		payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := `"aud":"https://example.a.run.app"`; !strings.Contains(string(payload), want) {
+			t.Errorf("want %s in %s", want, payload)
+		}
+
+		if got := util.GetSpanContextFromHTTPHeader(header); got == nil || got.TraceID != sc.TraceID {
+			t.Errorf("wrong trace context %v, want trace %s", got, sc.TraceID)
+		}
+	}
+
+	// the token is reused until it expires
+	if want, got := 1, *issued; want != got {
+		t.Errorf("wrong number of issued tokens %d, want %d", got, want)
+	}
+	if len(req.Header) != 0 {
+		t.Errorf("the original request is modified: %v", req.Header)
+	}
+}
+
+func TestNewAuthenticatedClientWithTokenError(t *testing.T) {
+	newFakeMetadataServer(t, true)
+	srv, _ := newHeaderServer(t)
+
+	if _, err := NewAuthenticatedClient("https://example.a.run.app").Get(srv.URL); err == nil {
+		t.Error("error is expected when ID token is not available")
+	}
+}
+
+func TestIDTokenTransport(t *testing.T) {
+	srv, headerCh := newHeaderServer(t)
+
+	tests := []struct {
+		provider util.IdentityProvider
+		want     string
+	}{
+		{provider: util.StaticIdentityProvider("static-token"), want: "Bearer static-token"},
+		// no Authorization header for local servers
+		{provider: util.InsecureIdentityProvider{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		client := &http.Client{Transport: &IDTokenTransport{
+			TokenSource: util.NewIDTokenSourceWithProvider("https://example.a.run.app", tt.provider),
+		}}
+
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+
+		if got := (<-headerCh).Get("Authorization"); got != tt.want {
+			t.Errorf("wrong Authorization %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+)
+
+// Checker reports the health of a dependency, e.g. ping to a database.
+// It returns nil if the dependency is healthy.
+type Checker func(ctx context.Context) error
+
+const (
+	healthStatusOK   = "ok"
+	healthStatusFail = "fail"
+)
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// HandleLiveness mounts the liveness probe handler on path.
+func (s *Server) HandleLiveness(path string, checkers map[string]Checker) {
+	s.Handle(path, healthHandler(checkers, nil))
+}
+
+// HandleReadiness mounts the readiness probe handler on path.
+// The readiness probe fails as soon as the shutdown of the server begins.
+func (s *Server) HandleReadiness(path string, checkers map[string]Checker) {
+	s.Handle(path, healthHandler(checkers, s.isShuttingDown))
+}
+
+// HandleStartup mounts the startup probe handler on path.
+func (s *Server) HandleStartup(path string, checkers map[string]Checker) {
+	s.Handle(path, healthHandler(checkers, nil))
+}
+
+func (s *Server) isShuttingDown() bool {
+	return atomic.LoadInt32(&s.shuttingDown) == 1
+}
+
+func healthHandler(checkers map[string]Checker, isShuttingDown func() bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := &healthResponse{
+			Status: healthStatusOK,
+			Checks: make(map[string]string, len(checkers)),
+		}
+
+		if isShuttingDown != nil && isShuttingDown() {
+			resp.Status = healthStatusFail
+			resp.Checks["shutdown"] = "server is shutting down"
+		}
+
+		for name, check := range checkers {
+			if err := check(r.Context()); err != nil {
+				resp.Status = healthStatusFail
+				resp.Checks[name] = err.Error()
+				continue
+			}
+			resp.Checks[name] = healthStatusOK
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if resp.Status != healthStatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			sharedLogger := zerolog.GetSharedLogger()
+			sharedLogger.Error().Msgf("failed to write health check response : %v", err)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	var okChecker = func(ctx context.Context) error {
+		return nil
+	}
+
+	var failChecker = func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}
+
+	tests := []struct {
+		handle         func(s *Server)
+		path           string
+		shuttingDown   bool
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			handle: func(s *Server) {
+				s.HandleLiveness("/healthz", nil)
+			},
+			path:           "/healthz",
+			wantStatusCode: http.StatusOK,
+			wantBody:       `{"status":"ok"}`,
+		},
+		{
+			handle: func(s *Server) {
+				s.HandleStartup("/startup", map[string]Checker{"db": okChecker})
+			},
+			path:           "/startup",
+			wantStatusCode: http.StatusOK,
+			wantBody:       `{"status":"ok","checks":{"db":"ok"}}`,
+		},
+		{
+			handle: func(s *Server) {
+				s.HandleReadiness("/ready", map[string]Checker{"db": okChecker, "cache": failChecker})
+			},
+			path:           "/ready",
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantBody:       `{"status":"fail","checks":{"cache":"connection refused","db":"ok"}}`,
+		},
+		{
+			handle: func(s *Server) {
+				s.HandleReadiness("/ready", map[string]Checker{"db": okChecker})
+			},
+			path:           "/ready",
+			shuttingDown:   true,
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantBody:       `{"status":"fail","checks":{"db":"ok","shutdown":"server is shutting down"}}`,
+		},
+		{
+			handle: func(s *Server) {
+				s.HandleLiveness("/healthz", nil)
+			},
+			path:           "/healthz",
+			shuttingDown:   true,
+			wantStatusCode: http.StatusOK,
+			wantBody:       `{"status":"ok"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		server := NewServer("google-sample-project")
+		tt.handle(server)
+		if tt.shuttingDown {
+			server.shuttingDown = 1
+		}
+
+		got := httptest.NewRecorder()
+		server.mux.ServeHTTP(got, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if want, got := tt.wantStatusCode, got.Code; want != got {
+			t.Errorf("want %d, got %d", want, got)
+		}
+		if want, got := tt.wantBody, strings.Trim(got.Body.String(), "\n"); want != got {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	}
+}
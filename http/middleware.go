@@ -5,7 +5,10 @@ import (
 	"net/http"
 
 	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"github.com/allabout/cloud-run-sdk/tracing"
 	"github.com/allabout/cloud-run-sdk/util"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Middleware func(http.Handler) http.Handler
@@ -20,27 +23,37 @@ func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
 func InjectLogger(projectID string) Middleware {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			sharedLogger := zerolog.GetSharedLogger()
+			// Logger is stored on every path, so that labels added by the handlers are kept through the context
+			logger := zerolog.NewLogger(zerolog.GetSharedLogger())
 
-			if !util.IsCloudRun() {
-				h.ServeHTTP(w, r.WithContext(sharedLogger.WithContext(r.Context())))
-				return
+			// the trace header is set by Cloud Run, and traceparent is preferred to X-Cloud-Trace-Context
+			var sc *util.SpanContext
+			if util.IsCloudRun() {
+				sc = util.GetSpanContextFromHTTPHeader(r.Header)
 			}
 
-			xCloudTraceContext := r.Header.Get("X-Cloud-Trace-Context")
-			if xCloudTraceContext == "" {
-				h.ServeHTTP(w, r.WithContext(sharedLogger.WithContext(r.Context())))
-				return
+			// the server span is recorded whenever tracing is set up, which is the root span without the trace header,
+			// and then logs are correlated to it. The path is kept in http.target rather than the name to group the spans
+			ctx, span, sc := tracing.Start(r.Context(), "HTTP "+r.Method, sc,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", "", r)...),
+			)
+			if span.IsRecording() {
+				rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+				defer func() { tracing.EndHTTP(span, rw.status) }()
+				w = rw
 			}
 
-			logger := zerolog.NewLogger(sharedLogger)
-
-			if traceID := util.GetTraceIDFromHeader(xCloudTraceContext); traceID != "" {
-				logger.AddTraceID(projectID, traceID)
+			if sc != nil {
+				logger.AddSpanContext(projectID, sc)
+				ctx = util.ContextWithSpanContext(ctx, sc)
 			}
-
-			r = r.WithContext(logger.WithContext(r.Context()))
-			r = r.WithContext(context.WithValue(r.Context(), "x-cloud-trace-context", xCloudTraceContext))
+			ctx = logger.WithContext(ctx)
+			// the raw header is kept for backward compatibility
+			if xCloudTraceContext := r.Header.Get("X-Cloud-Trace-Context"); util.IsCloudRun() && xCloudTraceContext != "" {
+				ctx = context.WithValue(ctx, "x-cloud-trace-context", xCloudTraceContext)
+			}
+			r = r.WithContext(ctx)
 
 			h.ServeHTTP(w, r)
 		})
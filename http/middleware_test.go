@@ -6,16 +6,25 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"testing"
 
 	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type logEntry struct {
-	Severity string `json:"severity"`
-	Trace    string `json:"logging.googleapis.com/trace"`
-	Message  string `json:"message"`
+	Severity     string `json:"severity"`
+	Trace        string `json:"logging.googleapis.com/trace"`
+	SpanID       string `json:"logging.googleapis.com/spanId"`
+	TraceSampled bool   `json:"logging.googleapis.com/trace_sampled"`
+	Message      string `json:"message"`
 }
 
 func TestInjectLogger(t *testing.T) {
@@ -41,9 +50,11 @@ func TestInjectLogger(t *testing.T) {
 				return nil, nil
 			},
 			want: logEntry{
-				Severity: "INFO",
-				Trace:    "projects/sample-google-project/traces/0123456789abcdef0123456789abcdef",
-				Message:  "info message",
+				Severity:     "INFO",
+				Trace:        "projects/sample-google-project/traces/0123456789abcdef0123456789abcdef",
+				SpanID:       "000000000000007b",
+				TraceSampled: true,
+				Message:      "info message",
 			},
 		},
 		{
@@ -63,9 +74,11 @@ func TestInjectLogger(t *testing.T) {
 				return nil, nil
 			},
 			want: logEntry{
-				Severity: "INFO",
-				Trace:    "projects/sample-google-project/traces/0123456789abcdef0123456789",
-				Message:  "info message",
+				Severity:     "INFO",
+				Trace:        "projects/sample-google-project/traces/0123456789abcdef0123456789",
+				SpanID:       "000000000000007b",
+				TraceSampled: true,
+				Message:      "info message",
 			},
 		},
 		{
@@ -84,9 +97,60 @@ func TestInjectLogger(t *testing.T) {
 				return nil, nil
 			},
 			want: logEntry{
-				Severity: "DEBUG",
-				Trace:    "projects/sample-google-project/traces/0123456789abcdef",
-				Message:  "debug message",
+				Severity:     "DEBUG",
+				Trace:        "projects/sample-google-project/traces/0123456789abcdef",
+				SpanID:       "000000000000007b",
+				TraceSampled: true,
+				Message:      "debug message",
+			},
+		},
+		{
+			debug: false,
+			requestFunc: func() *http.Request {
+				req, err := http.NewRequest("GET", "/", nil)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				// the trace is not sampled
+				req.Header.Add("X-Cloud-Trace-Context", "0123456789abcdef0123456789abcdef/123;o=0")
+				return req
+			},
+			appHandler: func(ctx context.Context) ([]byte, *AppError) {
+				logger := zerolog.Ctx(ctx)
+				logger.Info("info message")
+				return nil, nil
+			},
+			want: logEntry{
+				Severity:     "INFO",
+				Trace:        "projects/sample-google-project/traces/0123456789abcdef0123456789abcdef",
+				SpanID:       "000000000000007b",
+				TraceSampled: false,
+				Message:      "info message",
+			},
+		},
+		{
+			debug: false,
+			requestFunc: func() *http.Request {
+				req, err := http.NewRequest("GET", "/", nil)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				// traceparent is preferred
+				req.Header.Add("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+				req.Header.Add("X-Cloud-Trace-Context", "0123456789abcdef0123456789abcdef/123;o=0")
+				return req
+			},
+			appHandler: func(ctx context.Context) ([]byte, *AppError) {
+				logger := zerolog.Ctx(ctx)
+				logger.Info("info message")
+				return nil, nil
+			},
+			want: logEntry{
+				Severity:     "INFO",
+				Trace:        "projects/sample-google-project/traces/0af7651916cd43dd8448eb211c80319c",
+				SpanID:       "b7ad6b7169203331",
+				TraceSampled: true,
+				Message:      "info message",
 			},
 		},
 	}
@@ -108,3 +172,141 @@ func TestInjectLogger(t *testing.T) {
 		}
 	}
 }
+
+func TestInjectLoggerKeepsLabels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, false, false)
+
+	addLabels := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			zerolog.Ctx(r.Context()).AddLabels(map[string]string{"user": "alice"})
+			h.ServeHTTP(w, r)
+		})
+	}
+	var appHandler AppHandler = func(ctx context.Context) ([]byte, *AppError) {
+		zerolog.Ctx(ctx).Info("info message")
+		return nil, nil
+	}
+
+	// the request without trace header
+	Chain(appHandler, InjectLogger("sample-google-project"), addLabels).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	want := `{"severity":"INFO","logging.googleapis.com/labels":{"user":"alice"},"message":"info message"}` + "\n"
+	if got := buf.String(); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestInjectLoggerWithTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, false, true)
+
+	req := httptest.NewRequest("GET", "/hello", nil)
+	req.Header.Add("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	var appHandler AppHandler = func(ctx context.Context) ([]byte, *AppError) {
+		zerolog.Ctx(ctx).Info("info message")
+		return nil, Error(http.StatusServiceUnavailable, "unavailable")
+	}
+	Chain(appHandler, InjectLogger("sample-google-project")).ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("wrong number of spans %d, want 1", len(spans))
+	}
+	span := spans[0]
+
+	if want, got := "b7ad6b7169203331", span.Parent.SpanID().String(); want != got {
+		t.Errorf("wrong parent span id %s, want %s", got, want)
+	}
+	if want, got := "HTTP GET", span.Name; want != got {
+		t.Errorf("wrong span name %q, want %q", got, want)
+	}
+	var target string
+	for _, kv := range span.Attributes {
+		if kv.Key == semconv.HTTPTargetKey {
+			target = kv.Value.AsString()
+		}
+	}
+	if want := "/hello"; target != want {
+		t.Errorf("wrong http.target %q, want %q", target, want)
+	}
+	if want, got := trace.SpanKindServer, span.SpanKind; want != got {
+		t.Errorf("wrong span kind %v, want %v", got, want)
+	}
+	if want, got := codes.Error, span.Status.Code; want != got {
+		t.Errorf("wrong status %v, want %v", got, want)
+	}
+
+	// logs are correlated to the server span
+	var entry logEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := logEntry{
+		Severity:     "INFO",
+		Trace:        "projects/sample-google-project/traces/0af7651916cd43dd8448eb211c80319c",
+		SpanID:       span.SpanContext.SpanID().String(),
+		TraceSampled: true,
+		Message:      "info message",
+	}
+	if got := entry; !reflect.DeepEqual(want, got) {
+		t.Errorf("wrong response %#v, want %#v", got, want)
+	}
+}
+
+func TestInjectLoggerWithTracingOutsideCloudRun(t *testing.T) {
+	// the logger is set up before unsetting K_CONFIGURATION to write JSON
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, false, false)
+
+	if err := os.Unsetenv("K_CONFIGURATION"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("K_CONFIGURATION", "true")
+
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	// the request without trace header
+	var appHandler AppHandler = func(ctx context.Context) ([]byte, *AppError) {
+		zerolog.Ctx(ctx).Info("info message")
+		return nil, nil
+	}
+	Chain(appHandler, InjectLogger("sample-google-project")).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("wrong number of spans %d, want 1", len(spans))
+	}
+	span := spans[0]
+
+	// the root span is started
+	if span.Parent.IsValid() {
+		t.Errorf("want root span, got parent %v", span.Parent.SpanID())
+	}
+	if want, got := trace.SpanKindServer, span.SpanKind; want != got {
+		t.Errorf("wrong span kind %v, want %v", got, want)
+	}
+
+	// logs are correlated to the root span
+	var entry logEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := logEntry{
+		Severity:     "INFO",
+		Trace:        "projects/sample-google-project/traces/" + span.SpanContext.TraceID().String(),
+		SpanID:       span.SpanContext.SpanID().String(),
+		TraceSampled: true,
+		Message:      "info message",
+	}
+	if got := entry; !reflect.DeepEqual(want, got) {
+		t.Errorf("wrong response %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+)
+
+// Recovery recovers from panics in the handlers and responds 500 to the client.
+// The stack trace is logged at ERROR severity in the format of Go panic, which Cloud Error Reporting picks up.
+func Recovery() Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// http.ErrAbortHandler is the sentinel to abort the response, so it must not be recovered
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				zerolog.Ctx(r.Context()).Errorf("panic: %v\n\n%s", p, debug.Stack())
+
+				appErr := Error(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				w.WriteHeader(appErr.Code)
+				if err := json.NewEncoder(w).Encode(appErr); err != nil {
+					zerolog.Ctx(r.Context()).Error(err)
+				}
+			}()
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+)
+
+func TestRecovery(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, false, false)
+
+	var fn = func(ctx context.Context) ([]byte, *AppError) {
+		panic("something wrong")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("X-Cloud-Trace-Context", "0123456789abcdef0123456789abcdef/123;o=1")
+	got := httptest.NewRecorder()
+
+	Chain(AppHandler(fn), InjectLogger("google-sample-project"), Recovery()).ServeHTTP(got, req)
+
+	if want, got := http.StatusInternalServerError, got.Code; want != got {
+		t.Errorf("want %d, got %d", want, got)
+	}
+	if want, got := `{"code":500,"message":"Internal Server Error"}`, strings.Trim(got.Body.String(), "\n"); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	var entry logEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if want, got := "ERROR", entry.Severity; want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if want, got := "projects/google-sample-project/traces/0123456789abcdef0123456789abcdef", entry.Trace; want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if want := "panic: something wrong\n\ngoroutine "; !strings.HasPrefix(entry.Message, want) {
+		t.Errorf("want prefix %q, got %q", want, entry.Message)
+	}
+}
+
+func TestRecoveryWithErrorReporting(t *testing.T) {
+	for _, stackTrace := range []bool{true, false} {
+		buf := &bytes.Buffer{}
+		zerolog.SetSharedLogger(buf, false, false)
+		zerolog.EnableErrorReporting(stackTrace)
+
+		var fn = func(ctx context.Context) ([]byte, *AppError) {
+			panic("something wrong")
+		}
+
+		Chain(AppHandler(fn), InjectLogger("google-sample-project"), Recovery()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if _, ok := entry["@type"]; !ok {
+			t.Errorf("stackTrace=%v: want @type, got %v", stackTrace, entry)
+		}
+		// the stack trace of the panic in the message is reported as it is
+		if got, ok := entry["stack_trace"]; ok {
+			t.Errorf("stackTrace=%v: want no stack_trace, got %q", stackTrace, got)
+		}
+		if got, ok := entry["context"]; ok {
+			t.Errorf("stackTrace=%v: want no reportLocation of the recovery, got %v", stackTrace, got)
+		}
+		if message, _ := entry["message"].(string); strings.Count(message, "[running]:") != 1 {
+			t.Errorf("stackTrace=%v: want a stack trace in message, got %q", stackTrace, message)
+		}
+	}
+
+	// resets the hook
+	zerolog.SetSharedLogger(&bytes.Buffer{}, false, false)
+}
+
+func TestRecoveryWithAbortHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, false, false)
+
+	var fn = func(ctx context.Context) ([]byte, *AppError) {
+		panic(http.ErrAbortHandler)
+	}
+
+	defer func() {
+		if want, got := http.ErrAbortHandler, recover(); want != got {
+			t.Errorf("want %v, got %v", want, got)
+		}
+	}()
+
+	Chain(AppHandler(fn), InjectLogger("google-sample-project"), Recovery()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
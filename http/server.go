@@ -6,9 +6,15 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"github.com/allabout/cloud-run-sdk/util"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // It's usually a mistake to pass back the concrete type of an error rather than error,
@@ -64,13 +70,29 @@ func (fn AppHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// DefaultShutdownTimeout is the deadline to drain connections and run shutdown hooks.
+// Cloud Run sends SIGKILL 10 seconds after SIGTERM, so it must be shorter than that.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// ShutdownHook is called when the server shuts down, e.g. flush loggers, close DB pools.
+// ctx is cancelled when the shutdown timeout is exceeded.
+type ShutdownHook func(ctx context.Context) error
+
 type Server struct {
-	addr        string
-	mux         *http.ServeMux
-	middlewares []Middleware
-	srv         *http.Server
+	addr            string
+	mux             *http.ServeMux
+	middlewares     []Middleware
+	srv             *http.Server
+	shutdownTimeout time.Duration
+	shutdownHooks   []ShutdownHook
+	grpcHandler     http.Handler
+	grpcRequests    requestTracker
+	// set to 1 when the shutdown begins, which is accessed atomically
+	shuttingDown int32
 }
 
+// NewServerWithLogger creates Server whose middlewares are chained after InjectLogger,
+// so that the logs written by them such as AccessLog are correlated with the trace.
 func NewServerWithLogger(projectID string, middlewares ...Middleware) *Server {
 	return NewServer(projectID, append([]Middleware{InjectLogger(projectID)}, middlewares...)...)
 }
@@ -87,12 +109,90 @@ func NewServer(projectID string, middlewares ...Middleware) *Server {
 	}
 
 	return &Server{
-		addr:        hostAddr + ":" + port,
-		mux:         http.NewServeMux(),
-		middlewares: middlewares,
+		addr:            hostAddr + ":" + port,
+		mux:             http.NewServeMux(),
+		middlewares:     middlewares,
+		shutdownTimeout: DefaultShutdownTimeout,
+	}
+}
+
+// SetShutdownTimeout sets the deadline shared by draining connections and shutdown hooks.
+func (s *Server) SetShutdownTimeout(d time.Duration) {
+	s.shutdownTimeout = d
+}
+
+// OnShutdown registers hooks which are called in order after connections are drained.
+// They run with the remaining budget of the shutdown timeout.
+func (s *Server) OnShutdown(hooks ...ShutdownHook) {
+	s.shutdownHooks = append(s.shutdownHooks, hooks...)
+}
+
+// grpcShutdowner is implemented by grpc.Server, which sets the health status to NOT_SERVING.
+type grpcShutdowner interface {
+	Shutdown()
+}
+
+// HandleGRPC serves gRPC requests on the same listener as HTTP requests, e.g. pass grpc.Server.
+// Requests with content-type application/grpc are routed to h and the others to the handlers of the server.
+// Since Cloud Run routes requests only to $PORT, HTTP/2 without TLS (h2c) is enabled to serve gRPC end-to-end.
+// On shutdown, h is notified by Shutdown() if implemented, and in-flight gRPC requests are awaited within the shutdown timeout,
+// because http.Server.Shutdown doesn't wait for h2c connections.
+func (s *Server) HandleGRPC(h http.Handler) {
+	s.grpcHandler = h
+}
+
+// requestTracker counts in-flight requests so that the shutdown can wait for them.
+type requestTracker struct {
+	mu     sync.Mutex
+	active int
+	// idle is closed when active gets 0 while waiting
+	idle chan struct{}
+}
+
+func (t *requestTracker) start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active++
+}
+
+func (t *requestTracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active--
+	if t.active == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+func (t *requestTracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if t.active == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
+func (t *requestTracker) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.start()
+		defer t.done()
+		h.ServeHTTP(w, r)
+	})
+}
+
 func (s *Server) HandleWithRoot(h http.Handler, middlewares ...Middleware) {
 	s.HandleWithMiddleware("/", h, middlewares...)
 }
@@ -106,7 +206,27 @@ func (s *Server) Handle(path string, h http.Handler) {
 	s.mux.Handle(path, h)
 }
 
-func (s *Server) Start(stopCh <-chan struct{}) {
+func grpcHandlerFunc(grpcHandler, httpHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcHandler.ServeHTTP(w, r)
+			return
+		}
+		httpHandler.ServeHTTP(w, r)
+	})
+}
+
+// Start serves HTTP until stopCh is closed or the listener fails.
+// It returns nil on clean shutdown, and an error when the listener fails or the shutdown doesn't complete.
+func (s *Server) Start(stopCh <-chan struct{}) error {
+	ctx, cancel := util.ContextWithStopCh(stopCh)
+	defer cancel()
+
+	return s.Run(ctx)
+}
+
+// Run is the same as Start except that it serves HTTP until ctx is done.
+func (s *Server) Run(ctx context.Context) error {
 	sharedLogger := zerolog.GetSharedLogger()
 
 	s.srv = &http.Server{
@@ -114,20 +234,68 @@ func (s *Server) Start(stopCh <-chan struct{}) {
 		Handler: s.mux,
 	}
 
+	if s.grpcHandler != nil {
+		h2s := &http2.Server{}
+		// sends GOAWAY to h2c connections on Shutdown
+		if err := http2.ConfigureServer(s.srv, h2s); err != nil {
+			return fmt.Errorf("failed to configure HTTP/2: %w", err)
+		}
+		s.srv.Handler = h2c.NewHandler(grpcHandlerFunc(s.grpcRequests.handler(s.grpcHandler), s.mux), h2s)
+	}
+
+	errCh := make(chan error, 1)
 	go func() {
 		if err := s.srv.ListenAndServe(); err != http.ErrServerClosed {
-			sharedLogger.Error().Msgf("server closed with error : %v", err)
+			errCh <- err
 		}
 	}()
 
-	<-stopCh
-	sharedLogger.Info().Msg("recive SIGTERM or SIGINT")
+	var serveErr error
+	select {
+	case serveErr = <-errCh:
+		sharedLogger.Error().Msgf("server closed with error : %v", serveErr)
+	case <-ctx.Done():
+		sharedLogger.Info().Msg("recive SIGTERM or SIGINT")
+	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+	atomic.StoreInt32(&s.shuttingDown, 1)
+	// set gRPC services to NOT_SERVING before draining connections, as grpc.Server.Run does
+	if g, ok := s.grpcHandler.(grpcShutdowner); ok {
+		g.Shutdown()
+	}
 
-	if err := s.srv.Shutdown(ctx); err != nil {
-		sharedLogger.Error().Msgf("failed to shutdown HTTP Server : %v", err)
+	// ctx is already done, so the deadline is derived from a new context
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	shutdownErr := s.srv.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		sharedLogger.Error().Msgf("failed to shutdown HTTP Server : %v", shutdownErr)
+	}
+
+	// h2c connections are hijacked from the server, so in-flight gRPC requests are awaited separately
+	if err := s.grpcRequests.wait(shutdownCtx); err != nil {
+		sharedLogger.Error().Msgf("failed to drain gRPC requests : %v", err)
+		if shutdownErr == nil {
+			shutdownErr = fmt.Errorf("failed to drain gRPC requests: %w", err)
+		}
+	}
+
+	// hooks are called even if the server crashed to release resources
+	for _, hook := range s.shutdownHooks {
+		if err := hook(shutdownCtx); err != nil {
+			sharedLogger.Error().Msgf("failed to run shutdown hook : %v", err)
+		}
+	}
+
+	if serveErr != nil {
+		return fmt.Errorf("server closed with error: %w", serveErr)
+	}
+	if shutdownErr != nil {
+		return fmt.Errorf("failed to shutdown HTTP Server: %w", shutdownErr)
 	}
 
 	sharedLogger.Debug().Msg("HTTP Server shutdowned")
+
+	return nil
 }
@@ -3,6 +3,7 @@ package http
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -13,7 +14,14 @@ import (
 	"testing"
 	"time"
 
+	sdkgrpc "github.com/allabout/cloud-run-sdk/grpc"
 	"github.com/allabout/cloud-run-sdk/logging/zerolog"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/interop"
+	pb "google.golang.org/grpc/interop/grpc_testing"
 )
 
 func TestAppHandlerServeHTTP(t *testing.T) {
@@ -330,3 +338,200 @@ func TestStart(t *testing.T) {
 	}
 	mu.Unlock()
 }
+
+func TestShutdownHooks(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, false, false)
+
+	server := NewServerWithLogger("google-sample-project")
+	server.SetShutdownTimeout(time.Second)
+
+	var called []string
+	server.OnShutdown(
+		func(ctx context.Context) error {
+			called = append(called, "first")
+			return errors.New("failed to close db")
+		},
+		func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("want deadline of shutdown hook context")
+			}
+			called = append(called, "second")
+			return nil
+		},
+	)
+
+	stopCh := make(chan struct{})
+	close(stopCh)
+	server.Start(stopCh)
+
+	if want, got := []string{"first", "second"}, called; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	wantLog := `{"severity":"INFO","message":"recive SIGTERM or SIGINT"}` + "\n" +
+		`{"severity":"ERROR","message":"failed to run shutdown hook : failed to close db"}` + "\n"
+	if want, got := wantLog, buf.String(); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestStartWithListenerError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, false, false)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	server := NewServerWithLogger("google-sample-project")
+	// the address is already in use
+	server.addr = lis.Addr().String()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Start(make(chan struct{}))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Error("want error, got nil")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Start doesn't return on listener error")
+	}
+}
+
+func TestGRPCHandlerFunc(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, false, false)
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterTestServiceServer(grpcServer, interop.NewTestServer())
+
+	var rootFn = func(ctx context.Context) ([]byte, *AppError) {
+		return []byte("root"), nil
+	}
+
+	server := NewServerWithLogger("google-sample-project")
+	server.HandleWithRoot(AppHandler(rootFn))
+
+	ts := httptest.NewServer(h2c.NewHandler(grpcHandlerFunc(grpcServer, server.mux), &http2.Server{}))
+	defer ts.Close()
+
+	// HTTP/1.1 request is routed to mux
+	resp, err := http.DefaultClient.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if want, got := "root", string(respBody); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	// gRPC request over h2c is routed to gRPC server
+	conn, err := grpc.Dial(ts.Listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	client := pb.NewTestServiceClient(conn)
+	if _, err := client.EmptyCall(context.Background(), &pb.Empty{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestHandleGRPCShutdown(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zerolog.SetSharedLogger(buf, false, false)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	server := NewServerWithLogger("google-sample-project")
+	server.addr = addr
+	server.HandleGRPC(sdkgrpc.NewServer("google-sample-project"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Run(ctx)
+	}()
+
+	for count := 0; ; count++ {
+		conn, err := net.DialTimeout("tcp", addr, 300*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if count >= 5 {
+			t.Fatalf("failed to connect port for timeout : %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	streamCtx, streamCancel := context.WithCancel(context.Background())
+	defer streamCancel()
+	stream, err := healthpb.NewHealthClient(conn).Watch(streamCtx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := healthpb.HealthCheckResponse_SERVING, resp.Status; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	cancel()
+
+	// the health status is flipped before draining connections
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := healthpb.HealthCheckResponse_NOT_SERVING, resp.Status; want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// the in-flight stream is awaited
+	select {
+	case err := <-errCh:
+		t.Fatalf("Run returned before the gRPC stream is closed: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	streamCancel()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run doesn't return after the gRPC stream is closed")
+	}
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/allabout/cloud-run-sdk/tracing"
+	"github.com/allabout/cloud-run-sdk/util"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceTransport propagates the trace context stored by InjectLogger to outgoing requests
+// by both X-Cloud-Trace-Context and traceparent headers, so that the callee is traced as a child span.
+// Requests without trace context are sent as they are.
+type TraceTransport struct {
+	// Base is the RoundTripper to send requests. http.DefaultTransport is used if nil.
+	Base http.RoundTripper
+}
+
+func (t *TraceTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	parent := util.SpanContextFromContext(ctx)
+	if parent == nil {
+		return t.base().RoundTrip(req)
+	}
+
+	// each call is a new child span, which is recorded only when tracing is set up
+	ctx, span, sc := tracing.Start(ctx, "HTTP "+req.Method, parent,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+	)
+	if !span.IsRecording() {
+		sc = parent.NewChild()
+	}
+
+	// RoundTripper must not modify the original request
+	req = req.Clone(ctx)
+	req.Header.Set("X-Cloud-Trace-Context", sc.CloudTraceContext())
+	if traceparent := sc.Traceparent(); traceparent != "" {
+		req.Header.Set("traceparent", traceparent)
+		if sc.TraceState != "" {
+			req.Header.Set("tracestate", sc.TraceState)
+		}
+	}
+
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+	tracing.EndHTTP(span, resp.StatusCode)
+
+	return resp, nil
+}
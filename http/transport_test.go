@@ -0,0 +1,172 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/allabout/cloud-run-sdk/util"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newHeaderServer returns the server sending the received headers to the channel.
+func newHeaderServer(t *testing.T) (*httptest.Server, <-chan http.Header) {
+	headerCh := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headerCh <- r.Header
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, headerCh
+}
+
+func TestTraceTransport(t *testing.T) {
+	srv, headerCh := newHeaderServer(t)
+	client := &http.Client{Transport: &TraceTransport{}}
+
+	tests := []struct {
+		sc   *util.SpanContext
+		want *util.SpanContext
+	}{
+		{
+			sc:   &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true, TraceState: "congo=t61rcWkgMzE"},
+			want: &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", Sampled: true, TraceState: "congo=t61rcWkgMzE"},
+		},
+		{
+			// traceparent is not sent because the trace id is not 32 characters
+			sc:   &util.SpanContext{TraceID: "0123456789abcdef", SpanID: "000000000000007b", Sampled: false},
+			want: &util.SpanContext{TraceID: "0123456789abcdef", Sampled: false},
+		},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequestWithContext(util.ContextWithSpanContext(context.Background(), tt.sc), "GET", srv.URL, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		var spanIDs []string
+		for i := 0; i < 2; i++ {
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			header := <-headerCh
+			got := util.GetSpanContextFromHeader(header.Get("X-Cloud-Trace-Context"))
+			if got == nil || got.TraceID != tt.want.TraceID || got.Sampled != tt.want.Sampled {
+				t.Fatalf("wrong X-Cloud-Trace-Context %q, want trace %v", header.Get("X-Cloud-Trace-Context"), tt.want)
+			}
+
+			// both headers have the same span
+			if tp := util.GetSpanContextFromTraceparent(header.Get("traceparent"), header.Get("tracestate")); tp != nil {
+				want := *tt.want
+				want.SpanID = got.SpanID
+				if *tp != want {
+					t.Errorf("wrong traceparent %v, want %v", tp, want)
+				}
+			} else if len(tt.sc.TraceID) == 32 {
+				t.Errorf("traceparent is not sent")
+			}
+
+			if got.SpanID == tt.sc.SpanID {
+				t.Errorf("span id of the caller %s is sent", got.SpanID)
+			}
+			spanIDs = append(spanIDs, got.SpanID)
+		}
+
+		if spanIDs[0] == spanIDs[1] {
+			t.Errorf("span id %s is shared by the calls", spanIDs[0])
+		}
+		if len(req.Header) != 0 {
+			t.Errorf("the original request is modified: %v", req.Header)
+		}
+	}
+}
+
+func TestTraceTransportWithoutTrace(t *testing.T) {
+	srv, headerCh := newHeaderServer(t)
+	client := &http.Client{Transport: &TraceTransport{}}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	header := <-headerCh
+	for _, key := range []string{"X-Cloud-Trace-Context", "traceparent"} {
+		if got := header.Get(key); got != "" {
+			t.Errorf("%s is sent: %q", key, got)
+		}
+	}
+}
+
+func TestTraceTransportWithoutSampledFlag(t *testing.T) {
+	srv, headerCh := newHeaderServer(t)
+	client := &http.Client{Transport: &TraceTransport{}}
+
+	// the caller hasn't decided the sampling
+	sc := util.GetSpanContextFromHeader("0123456789abcdef0123456789abcdef/123")
+	req, err := http.NewRequestWithContext(util.ContextWithSpanContext(context.Background(), sc), "GET", srv.URL, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	header := <-headerCh
+	got := header.Get("X-Cloud-Trace-Context")
+	if want := "0123456789abcdef0123456789abcdef/"; !strings.HasPrefix(got, want) || strings.Contains(got, ";o=") {
+		t.Errorf("wrong X-Cloud-Trace-Context %q, want %q without o flag", got, want+"<span id>")
+	}
+}
+
+func TestTraceTransportWithTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	srv, headerCh := newHeaderServer(t)
+	client := &http.Client{Transport: &TraceTransport{}}
+
+	sc := &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true}
+	req, err := http.NewRequestWithContext(util.ContextWithSpanContext(context.Background(), sc), "GET", srv.URL, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("wrong number of spans %d, want 1", len(spans))
+	}
+	span := spans[0]
+
+	if want, got := trace.SpanKindClient, span.SpanKind; want != got {
+		t.Errorf("wrong span kind %v, want %v", got, want)
+	}
+	if want, got := sc.SpanID, span.Parent.SpanID().String(); want != got {
+		t.Errorf("wrong parent span id %s, want %s", got, want)
+	}
+
+	// the client span is propagated as the parent of the callee
+	header := <-headerCh
+	want := "00-0af7651916cd43dd8448eb211c80319c-" + span.SpanContext.SpanID().String() + "-01"
+	if got := header.Get("traceparent"); want != got {
+		t.Errorf("wrong traceparent %q, want %q", got, want)
+	}
+}
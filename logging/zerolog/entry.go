@@ -0,0 +1,101 @@
+package zerolog
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// operation groups the entries of a long-running operation in Cloud Logging.
+// see. https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogEntryOperation
+type operation struct {
+	id       string
+	producer string
+	// 1 until the first entry of the operation is written, which is accessed atomically
+	pendingFirst int32
+}
+
+// InsertID sets the unique identifier of the entry, which is used by Cloud Logging to deduplicate entries.
+func InsertID(id string) Field {
+	return func(e *zerolog.Event) { e.Str("logging.googleapis.com/insertId", id) }
+}
+
+// AddLabels adds labels to the entries of the logger, which are merged with the labels already added.
+func (l *Logger) AddLabels(labels map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.labels == nil {
+		l.labels = make(map[string]string, len(labels))
+	}
+	for k, v := range labels {
+		l.labels[k] = v
+	}
+}
+
+// BeginOperation creates a child logger whose entries are grouped by the operation of id and producer.
+// The first entry written by the child logger is marked as the first entry of the operation.
+func (l *Logger) BeginOperation(id, producer string) *Logger {
+	return l.withOperation(&operation{id: id, producer: producer, pendingFirst: 1})
+}
+
+// ContinueOperation creates a child logger whose entries are grouped by the operation of id and producer,
+// which has been begun by another logger, e.g. the previous step of a batch job.
+func (l *Logger) ContinueOperation(id, producer string) *Logger {
+	return l.withOperation(&operation{id: id, producer: producer})
+}
+
+// EndOperation writes the last entry of the operation at INFO level.
+func (l *Logger) EndOperation(args ...interface{}) {
+	l.withEntryFields(l.zerologger.Info(), true).Msg(fmt.Sprint(args...))
+}
+
+func (l *Logger) withOperation(op *operation) *Logger {
+	child := l.With().Logger()
+	child.operation = op
+	return child
+}
+
+// entry adds the fields which are rendered at each entry to e.
+func (l *Logger) entry(e *zerolog.Event) *zerolog.Event {
+	return l.withEntryFields(e, false)
+}
+
+func (l *Logger) withEntryFields(e *zerolog.Event, last bool) *zerolog.Event {
+	// e is nil when the level is disabled
+	if e == nil {
+		return e
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if len(l.labels) > 0 {
+		keys := make([]string, 0, len(l.labels))
+		for k := range l.labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		labels := zerolog.Dict()
+		for _, k := range keys {
+			labels.Str(k, l.labels[k])
+		}
+		e.Dict("logging.googleapis.com/labels", labels)
+	}
+
+	if op := l.operation; op != nil {
+		d := zerolog.Dict().Str("id", op.id).Str("producer", op.producer)
+		if atomic.CompareAndSwapInt32(&op.pendingFirst, 1, 0) {
+			d.Bool("first", true)
+		}
+		if last {
+			d.Bool("last", true)
+		}
+		e.Dict("logging.googleapis.com/operation", d)
+	}
+
+	return e
+}
@@ -0,0 +1,68 @@
+package zerolog
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestAddLabels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetSharedLogger(buf, true, false)
+	logger := NewLogger(GetSharedLogger())
+
+	logger.AddLabels(map[string]string{"env": "dev", "team": "a"})
+	ctx := logger.WithContext(context.Background())
+	// labels are kept and merged through the context
+	Ctx(ctx).AddLabels(map[string]string{"team": "b"})
+
+	child := Ctx(ctx).With().Str("job", "batch").Logger()
+	child.AddLabels(map[string]string{"child": "true"})
+
+	Ctx(ctx).Info("message")
+	child.Info("child message")
+
+	want := `{"severity":"INFO","logging.googleapis.com/labels":{"env":"dev","team":"b"},"message":"message"}` + "\n" +
+		`{"severity":"INFO","job":"batch","logging.googleapis.com/labels":{"child":"true","env":"dev","team":"b"},"message":"child message"}` + "\n"
+	if got := buf.String(); want != got {
+		t.Errorf("got %q, want = %q", got, want)
+	}
+}
+
+func TestOperation(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetSharedLogger(buf, true, false)
+	logger := NewLogger(GetSharedLogger())
+
+	op := logger.BeginOperation("job-1", "github.com/allabout/batch")
+	op.Info("step 1")
+	op.Info("step 2")
+
+	cont := logger.ContinueOperation("job-1", "github.com/allabout/batch")
+	cont.Info("step 3")
+	cont.EndOperation("done")
+
+	logger.Info("outside operation")
+
+	want := `{"severity":"INFO","logging.googleapis.com/operation":{"id":"job-1","producer":"github.com/allabout/batch","first":true},"message":"step 1"}` + "\n" +
+		`{"severity":"INFO","logging.googleapis.com/operation":{"id":"job-1","producer":"github.com/allabout/batch"},"message":"step 2"}` + "\n" +
+		`{"severity":"INFO","logging.googleapis.com/operation":{"id":"job-1","producer":"github.com/allabout/batch"},"message":"step 3"}` + "\n" +
+		`{"severity":"INFO","logging.googleapis.com/operation":{"id":"job-1","producer":"github.com/allabout/batch","last":true},"message":"done"}` + "\n" +
+		`{"severity":"INFO","message":"outside operation"}` + "\n"
+	if got := buf.String(); want != got {
+		t.Errorf("got %q, want = %q", got, want)
+	}
+}
+
+func TestInsertID(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetSharedLogger(buf, true, false)
+	logger := NewLogger(GetSharedLogger())
+
+	logger.Infow("message", InsertID("entry-1"))
+
+	want := `{"severity":"INFO","logging.googleapis.com/insertId":"entry-1","message":"message"}` + "\n"
+	if got := buf.String(); want != got {
+		t.Errorf("got %q, want = %q", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package zerolog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/allabout/cloud-run-sdk/util"
+	"github.com/rs/zerolog"
+)
+
+// reportedErrorEventType makes Cloud Error Reporting pick up the log entry regardless of the stack trace.
+// see. https://cloud.google.com/error-reporting/docs/formatting-error-messages
+const reportedErrorEventType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// ErrorReportingHook implements zerolog.Hook interface.
+type ErrorReportingHook struct {
+	// StackTrace attaches stack_trace of the caller, otherwise reportLocation is attached instead.
+	StackTrace bool
+
+	service string
+	version string
+}
+
+func NewErrorReportingHook(stackTrace bool) *ErrorReportingHook {
+	return &ErrorReportingHook{
+		StackTrace: stackTrace,
+		service:    os.Getenv("K_SERVICE"),
+		version:    os.Getenv("K_REVISION"),
+	}
+}
+
+// Run adds the fields of ReportedErrorEvent to zerolog.Event of ERROR or higher level.
+func (h *ErrorReportingHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if level < zerolog.ErrorLevel {
+		return
+	}
+
+	e.Str("@type", reportedErrorEventType)
+	e.Dict("serviceContext", zerolog.Dict().Str("service", h.service).Str("version", h.version))
+
+	// the message logged by Recovery already has the stack trace of the panic, which Error Reporting parses,
+	// so the stack of the logger caller, i.e. the recovery site, is not added
+	if hasStackTrace(msg) {
+		return
+	}
+
+	frames := callerFrames()
+	if h.StackTrace {
+		e.Str("stack_trace", formatStackTrace(msg, frames))
+		return
+	}
+
+	if len(frames) > 0 {
+		e.Dict("context", zerolog.Dict().Dict("reportLocation", zerolog.Dict().
+			Str("filePath", frames[0].File).
+			Int("lineNumber", frames[0].Line).
+			Str("functionName", frames[0].Function)))
+	}
+}
+
+// EnableErrorReporting formats the entries of ERROR or higher level of the shared logger for Cloud Error Reporting.
+// It should be called after SetSharedLogger, and is not thread-safe as well.
+func EnableErrorReporting(stackTrace bool) {
+	if !util.IsCloudRun() {
+		return
+	}
+
+	sharedLogger = sharedLogger.Hook(NewErrorReportingHook(stackTrace))
+}
+
+// callerFrames returns the stack frames of the caller of Logger, which excludes the frames inside loggers.
+func callerFrames() []runtime.Frame {
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	var callers []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		if len(callers) > 0 || !isLoggerFrame(frame.Function) {
+			callers = append(callers, frame)
+		}
+		if !more {
+			break
+		}
+	}
+
+	return callers
+}
+
+func isLoggerFrame(function string) bool {
+	return strings.HasPrefix(function, "github.com/rs/zerolog.") ||
+		strings.HasPrefix(function, "github.com/allabout/cloud-run-sdk/logging/zerolog.(*")
+}
+
+// hasStackTrace reports whether msg contains the goroutine dump of runtime/debug.Stack.
+func hasStackTrace(msg string) bool {
+	return strings.Contains(msg, "\n\ngoroutine ") && strings.Contains(msg, " [running]:\n")
+}
+
+// formatStackTrace formats frames in the same way as runtime/debug.Stack, which Error Reporting can parse.
+func formatStackTrace(msg string, frames []runtime.Frame) string {
+	var sb strings.Builder
+	sb.WriteString(msg)
+	sb.WriteString("\n\ngoroutine 1 [running]:\n")
+	for _, f := range frames {
+		fmt.Fprintf(&sb, "%s()\n\t%s:%d\n", f.Function, f.File, f.Line)
+	}
+
+	return sb.String()
+}
+
+// Err logs err at ERROR level with errorChain field, which renders the type and message of each wrapped error.
+// It does nothing if err is nil.
+func (l *Logger) Err(err error) {
+	if err == nil {
+		return
+	}
+
+	chain := zerolog.Arr()
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		chain.Object(&chainedError{e})
+	}
+
+	l.entry(l.zerologger.Error()).Array("errorChain", chain).Msg(err.Error())
+}
+
+type chainedError struct {
+	err error
+}
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler interface.
+func (c *chainedError) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", fmt.Sprintf("%T", c.err)).Str("message", c.err.Error())
+}
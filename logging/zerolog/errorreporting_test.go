@@ -0,0 +1,110 @@
+package zerolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+type errorReportingEntry struct {
+	Severity       string `json:"severity"`
+	Message        string `json:"message"`
+	Type           string `json:"@type"`
+	ServiceContext struct {
+		Service string `json:"service"`
+		Version string `json:"version"`
+	} `json:"serviceContext"`
+	StackTrace string `json:"stack_trace"`
+	Context    struct {
+		ReportLocation struct {
+			FunctionName string `json:"functionName"`
+		} `json:"reportLocation"`
+	} `json:"context"`
+}
+
+func TestEnableErrorReporting(t *testing.T) {
+	for _, env := range []struct{ key, value string }{{"K_SERVICE", "sample-service"}, {"K_REVISION", "sample-service-00001"}} {
+		if err := os.Setenv(env.key, env.value); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Unsetenv(env.key)
+	}
+
+	const testFunction = "github.com/allabout/cloud-run-sdk/logging/zerolog.TestEnableErrorReporting"
+
+	for _, tt := range []struct {
+		stackTrace bool
+		errorw     bool
+	}{
+		{stackTrace: true},
+		{stackTrace: false},
+		{stackTrace: true, errorw: true},
+		{stackTrace: false, errorw: true},
+	} {
+		buffer = &bytes.Buffer{}
+		SetSharedLogger(buffer, true, false)
+		EnableErrorReporting(tt.stackTrace)
+		logger := NewLogger(GetSharedLogger())
+
+		logger.Info("info message")
+		if want, got := `{"severity":"INFO","message":"info message"}`+"\n", buffer.String(); want != got {
+			t.Errorf("want %q, got %q", want, got)
+		}
+
+		buffer.Reset()
+		if tt.errorw {
+			logger.Errorw("error message", String("key", "value"))
+		} else {
+			logger.Error("error message")
+		}
+
+		var entry errorReportingEntry
+		if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if want, got := reportedErrorEventType, entry.Type; want != got {
+			t.Errorf("want %q, got %q", want, got)
+		}
+		if want, got := "sample-service", entry.ServiceContext.Service; want != got {
+			t.Errorf("want %q, got %q", want, got)
+		}
+		if want, got := "sample-service-00001", entry.ServiceContext.Version; want != got {
+			t.Errorf("want %q, got %q", want, got)
+		}
+
+		if tt.stackTrace {
+			if want := "error message\n\ngoroutine 1 [running]:\n" + testFunction + "()\n"; !strings.HasPrefix(entry.StackTrace, want) {
+				t.Errorf("want prefix %q, got %q", want, entry.StackTrace)
+			}
+		} else {
+			if want, got := testFunction, entry.Context.ReportLocation.FunctionName; want != got {
+				t.Errorf("want %q, got %q", want, got)
+			}
+		}
+	}
+}
+
+func TestErr(t *testing.T) {
+	buffer = &bytes.Buffer{}
+	SetSharedLogger(buffer, true, false)
+	logger := NewLogger(GetSharedLogger())
+
+	err := fmt.Errorf("failed to load config: %w", errors.New("file not found"))
+	logger.Err(err)
+
+	want := `{"severity":"ERROR","errorChain":[{"type":"*fmt.wrapError","message":"failed to load config: file not found"},{"type":"*errors.errorString","message":"file not found"}],"message":"failed to load config: file not found"}`
+	if got := strings.TrimRight(buffer.String(), "\n"); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	buffer.Reset()
+	logger.Err(nil)
+	if buffer.Len() != 0 {
+		t.Errorf("want no output, got %q", buffer.String())
+	}
+}
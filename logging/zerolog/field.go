@@ -0,0 +1,144 @@
+package zerolog
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Field is a typed key/value pair which is attached to a log entry as a JSON field.
+type Field func(e *zerolog.Event)
+
+func String(key, val string) Field {
+	return func(e *zerolog.Event) { e.Str(key, val) }
+}
+
+func Strings(key string, vals []string) Field {
+	return func(e *zerolog.Event) { e.Strs(key, vals) }
+}
+
+func Int(key string, val int) Field {
+	return func(e *zerolog.Event) { e.Int(key, val) }
+}
+
+func Int64(key string, val int64) Field {
+	return func(e *zerolog.Event) { e.Int64(key, val) }
+}
+
+func Float64(key string, val float64) Field {
+	return func(e *zerolog.Event) { e.Float64(key, val) }
+}
+
+func Bool(key string, val bool) Field {
+	return func(e *zerolog.Event) { e.Bool(key, val) }
+}
+
+// Duration renders val in zerolog.DurationFieldUnit, which is millisecond by default.
+func Duration(key string, val time.Duration) Field {
+	return func(e *zerolog.Event) { e.Dur(key, val) }
+}
+
+func Time(key string, val time.Time) Field {
+	return func(e *zerolog.Event) { e.Time(key, val) }
+}
+
+// Any renders val by encoding/json.
+func Any(key string, val interface{}) Field {
+	return func(e *zerolog.Event) { e.Interface(key, val) }
+}
+
+// logWithFields is a method so that the frame is skipped as a logger frame by Error Reporting.
+func (l *Logger) logWithFields(e *zerolog.Event, msg string, fields []Field) {
+	for _, field := range fields {
+		field(e)
+	}
+	e.Msg(msg)
+}
+
+func (l *Logger) Debugw(msg string, fields ...Field) {
+	l.logWithFields(l.entry(l.zerologger.Debug()), msg, fields)
+}
+
+func (l *Logger) Infow(msg string, fields ...Field) {
+	l.logWithFields(l.entry(l.zerologger.Info()), msg, fields)
+}
+
+func (l *Logger) Warnw(msg string, fields ...Field) {
+	l.logWithFields(l.entry(l.zerologger.Warn()), msg, fields)
+}
+
+func (l *Logger) Errorw(msg string, fields ...Field) {
+	l.logWithFields(l.entry(l.zerologger.Error()), msg, fields)
+}
+
+// Context is used to create a child logger with persistent fields.
+type Context struct {
+	ctx    zerolog.Context
+	parent *Logger
+}
+
+// With creates a child logger with the fields added to its context, e.g. logger.With().Str("key", "value").Logger().
+// The child logger keeps the fields of the parent such as trace.
+func (l *Logger) With() *Context {
+	return &Context{ctx: l.zerologger.With(), parent: l}
+}
+
+// Logger returns the child logger with the fields.
+func (c *Context) Logger() *Logger {
+	logger := c.ctx.Logger()
+
+	c.parent.mu.RLock()
+	defer c.parent.mu.RUnlock()
+
+	labels := make(map[string]string, len(c.parent.labels))
+	for k, v := range c.parent.labels {
+		labels[k] = v
+	}
+
+	return &Logger{zerologger: &logger, labels: labels, operation: c.parent.operation}
+}
+
+func (c *Context) Str(key, val string) *Context {
+	c.ctx = c.ctx.Str(key, val)
+	return c
+}
+
+func (c *Context) Strs(key string, vals []string) *Context {
+	c.ctx = c.ctx.Strs(key, vals)
+	return c
+}
+
+func (c *Context) Int(key string, val int) *Context {
+	c.ctx = c.ctx.Int(key, val)
+	return c
+}
+
+func (c *Context) Int64(key string, val int64) *Context {
+	c.ctx = c.ctx.Int64(key, val)
+	return c
+}
+
+func (c *Context) Float64(key string, val float64) *Context {
+	c.ctx = c.ctx.Float64(key, val)
+	return c
+}
+
+func (c *Context) Bool(key string, val bool) *Context {
+	c.ctx = c.ctx.Bool(key, val)
+	return c
+}
+
+func (c *Context) Dur(key string, val time.Duration) *Context {
+	c.ctx = c.ctx.Dur(key, val)
+	return c
+}
+
+func (c *Context) Time(key string, val time.Time) *Context {
+	c.ctx = c.ctx.Time(key, val)
+	return c
+}
+
+func (c *Context) Interface(key string, val interface{}) *Context {
+	c.ctx = c.ctx.Interface(key, val)
+	return c
+}
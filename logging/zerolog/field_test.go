@@ -0,0 +1,69 @@
+package zerolog
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogWithFields(t *testing.T) {
+	for _, tt := range []struct {
+		logFunc func(l *Logger)
+		want    string
+	}{
+		{
+			func(l *Logger) {
+				l.Debugw("debug message", String("user", "alice"), Int("count", 3))
+			},
+			`{"severity":"DEBUG","user":"alice","count":3,"message":"debug message"}`,
+		},
+		{
+			func(l *Logger) {
+				l.Infow("info message", Bool("cached", true), Float64("ratio", 0.5), Int64("bytes", 1024))
+			},
+			`{"severity":"INFO","cached":true,"ratio":0.5,"bytes":1024,"message":"info message"}`,
+		},
+		{
+			func(l *Logger) {
+				l.Warnw("warning message", Strings("ids", []string{"a", "b"}), Duration("elapsed", 1500*time.Millisecond))
+			},
+			`{"severity":"WARNING","ids":["a","b"],"elapsed":1500,"message":"warning message"}`,
+		},
+		{
+			func(l *Logger) {
+				l.Errorw("error message", Any("detail", map[string]int{"retry": 2}), Time("at", time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)))
+			},
+			`{"severity":"ERROR","detail":{"retry":2},"at":"2021-06-01T00:00:00Z","message":"error message"}`,
+		},
+	} {
+		buf := &bytes.Buffer{}
+		SetSharedLogger(buf, true, false)
+		logger := NewLogger(GetSharedLogger())
+
+		tt.logFunc(logger)
+		output := strings.TrimRight(buf.String(), "\n")
+		if output != tt.want {
+			t.Errorf("got %q, want = %q", output, tt.want)
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetSharedLogger(buf, true, false)
+	logger := NewLogger(GetSharedLogger())
+	logger.AddTraceID("sample-google-project", "0123456789abcdef")
+
+	child := logger.With().Str("job", "batch").Int("shard", 1).Bool("dryRun", false).Interface("err", errors.New("x").Error()).Logger()
+
+	child.Info("child message")
+	logger.Info("parent message")
+
+	want := `{"severity":"INFO","logging.googleapis.com/trace":"projects/sample-google-project/traces/0123456789abcdef","job":"batch","shard":1,"dryRun":false,"err":"x","message":"child message"}` + "\n" +
+		`{"severity":"INFO","logging.googleapis.com/trace":"projects/sample-google-project/traces/0123456789abcdef","message":"parent message"}` + "\n"
+	if got := buf.String(); want != got {
+		t.Errorf("got %q, want = %q", got, want)
+	}
+}
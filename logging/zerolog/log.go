@@ -71,16 +71,26 @@ func GetSharedLogger() zerolog.Logger {
 
 type Logger struct {
 	zerologger *zerolog.Logger
+
+	// fields which are rendered at each entry rather than stored in the context of zerologger
+	mu        sync.RWMutex
+	labels    map[string]string
+	operation *operation
 }
 
 // creates a child logger from shared logger
 func NewLogger(sharedLogger zerolog.Logger) *Logger {
 	logger := sharedLogger.With().Logger()
-	return &Logger{&logger}
+	return &Logger{zerologger: &logger}
 }
 
+type loggerKey struct{}
+
 func Ctx(ctx context.Context) *Logger {
-	return &Logger{log.Ctx(ctx)}
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return &Logger{zerologger: log.Ctx(ctx)}
 }
 
 func (l *Logger) AddTraceID(projectID, traceID string) {
@@ -89,6 +99,17 @@ func (l *Logger) AddTraceID(projectID, traceID string) {
 	})
 }
 
+// AddSpanContext adds trace, spanId and trace_sampled, so that the entries are nested under the span in Cloud Trace.
+func (l *Logger) AddSpanContext(projectID string, sc *util.SpanContext) {
+	l.zerologger.UpdateContext(func(c zerolog.Context) zerolog.Context {
+		c = c.Str("logging.googleapis.com/trace", fmt.Sprintf("projects/%s/traces/%s", projectID, sc.TraceID))
+		if sc.SpanID != "" {
+			c = c.Str("logging.googleapis.com/spanId", sc.SpanID)
+		}
+		return c.Bool("logging.googleapis.com/trace_sampled", sc.Sampled)
+	})
+}
+
 func (l *Logger) AddMethod(fullMethod string) {
 	l.zerologger.UpdateContext(func(c zerolog.Context) zerolog.Context {
 		return c.Str("method", fullMethod)
@@ -96,45 +117,46 @@ func (l *Logger) AddMethod(fullMethod string) {
 }
 
 func (l *Logger) WithContext(ctx context.Context) context.Context {
-	return l.zerologger.WithContext(ctx)
+	// Logger is also stored to keep labels and operation, which zerolog.Logger doesn't have
+	return context.WithValue(l.zerologger.WithContext(ctx), loggerKey{}, l)
 }
 
 func (l *Logger) Debug(args ...interface{}) {
-	l.zerologger.Debug().Msg(fmt.Sprint(args...))
+	l.entry(l.zerologger.Debug()).Msg(fmt.Sprint(args...))
 }
 
 func (l *Logger) Debugf(format string, args ...interface{}) {
-	l.zerologger.Debug().Msgf(format, args...)
+	l.entry(l.zerologger.Debug()).Msgf(format, args...)
 }
 
 func (l *Logger) Info(args ...interface{}) {
-	l.zerologger.Info().Msg(fmt.Sprint(args...))
+	l.entry(l.zerologger.Info()).Msg(fmt.Sprint(args...))
 }
 
 func (l *Logger) Infof(format string, args ...interface{}) {
-	l.zerologger.Info().Msgf(format, args...)
+	l.entry(l.zerologger.Info()).Msgf(format, args...)
 }
 
 func (l *Logger) Warn(args ...interface{}) {
-	l.zerologger.Warn().Msg(fmt.Sprint(args...))
+	l.entry(l.zerologger.Warn()).Msg(fmt.Sprint(args...))
 }
 
 func (l *Logger) Warnf(format string, args ...interface{}) {
-	l.zerologger.Warn().Msgf(format, args...)
+	l.entry(l.zerologger.Warn()).Msgf(format, args...)
 }
 
 func (l *Logger) Error(args ...interface{}) {
-	l.zerologger.Error().Msg(fmt.Sprint(args...))
+	l.entry(l.zerologger.Error()).Msg(fmt.Sprint(args...))
 }
 
 func (l *Logger) Errorf(format string, args ...interface{}) {
-	l.zerologger.Error().Msgf(format, args...)
+	l.entry(l.zerologger.Error()).Msgf(format, args...)
 }
 
 func (l *Logger) Fatal(args ...interface{}) {
-	l.zerologger.Fatal().Msg(fmt.Sprint(args...))
+	l.entry(l.zerologger.Fatal()).Msg(fmt.Sprint(args...))
 }
 
 func (l *Logger) Fatalf(format string, args ...interface{}) {
-	l.zerologger.Fatal().Msgf(format, args...)
+	l.entry(l.zerologger.Fatal()).Msgf(format, args...)
 }
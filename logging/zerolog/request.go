@@ -0,0 +1,98 @@
+package zerolog
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+)
+
+// HTTPRequest is the information about the HTTP request which is rendered natively by Cloud Logging.
+// see. https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+type HTTPRequest struct {
+	RequestMethod string
+	RequestURL    string
+	RequestSize   int64
+	Status        int
+	ResponseSize  int64
+	UserAgent     string
+	RemoteIP      string
+	Referer       string
+	Latency       time.Duration
+	Protocol      string
+}
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler interface.
+func (r *HTTPRequest) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("requestMethod", r.RequestMethod).
+		Str("requestUrl", r.RequestURL).
+		Int("status", r.Status).
+		Str("responseSize", strconv.FormatInt(r.ResponseSize, 10)).
+		Str("userAgent", r.UserAgent).
+		Str("remoteIp", r.RemoteIP).
+		Str("latency", fmt.Sprintf("%.9fs", r.Latency.Seconds())).
+		Str("protocol", r.Protocol)
+
+	if r.RequestSize > 0 {
+		e.Str("requestSize", strconv.FormatInt(r.RequestSize, 10))
+	}
+	if r.Referer != "" {
+		e.Str("referer", r.Referer)
+	}
+}
+
+// LogHTTPRequest writes the access log with httpRequest field.
+// The severity is ERROR for 5xx status, WARNING for 4xx status and INFO for the others.
+func (l *Logger) LogHTTPRequest(r *HTTPRequest) {
+	var e *zerolog.Event
+	switch {
+	case r.Status >= http.StatusInternalServerError:
+		e = l.entry(l.zerologger.Error())
+	case r.Status >= http.StatusBadRequest:
+		e = l.entry(l.zerologger.Warn())
+	default:
+		e = l.entry(l.zerologger.Info())
+	}
+
+	e.Object("httpRequest", r).Msgf("%s %s %d", r.RequestMethod, r.RequestURL, r.Status)
+}
+
+// GRPCRequest is the information about the gRPC call.
+type GRPCRequest struct {
+	Method       string
+	Code         codes.Code
+	Latency      time.Duration
+	PeerAddress  string
+	RequestSize  int64
+	ResponseSize int64
+}
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler interface.
+func (r *GRPCRequest) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("method", r.Method).
+		Str("code", r.Code.String()).
+		Str("latency", fmt.Sprintf("%.9fs", r.Latency.Seconds())).
+		Str("peerAddress", r.PeerAddress).
+		Str("requestSize", strconv.FormatInt(r.RequestSize, 10)).
+		Str("responseSize", strconv.FormatInt(r.ResponseSize, 10))
+}
+
+// LogGRPCRequest writes the access log with grpcRequest field.
+// The severity is WARNING for the codes caused by the client, ERROR for the codes caused by the server and INFO for OK.
+func (l *Logger) LogGRPCRequest(r *GRPCRequest) {
+	var e *zerolog.Event
+	switch r.Code {
+	case codes.OK:
+		e = l.entry(l.zerologger.Info())
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+		codes.ResourceExhausted, codes.FailedPrecondition, codes.Aborted, codes.OutOfRange, codes.Unauthenticated:
+		e = l.entry(l.zerologger.Warn())
+	default:
+		e = l.entry(l.zerologger.Error())
+	}
+
+	e.Object("grpcRequest", r).Msgf("%s %s", r.Method, r.Code)
+}
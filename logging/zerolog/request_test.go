@@ -0,0 +1,70 @@
+package zerolog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestLogHTTPRequest(t *testing.T) {
+	for _, tt := range []struct {
+		req  *HTTPRequest
+		want string
+	}{
+		{
+			&HTTPRequest{RequestMethod: "GET", RequestURL: "https://example.com/", Status: 200, ResponseSize: 2, Latency: 1500 * time.Millisecond, Protocol: "HTTP/1.1"},
+			`{"severity":"INFO","httpRequest":{"requestMethod":"GET","requestUrl":"https://example.com/","status":200,"responseSize":"2","userAgent":"","remoteIp":"","latency":"1.500000000s","protocol":"HTTP/1.1"},"message":"GET https://example.com/ 200"}`,
+		},
+		{
+			&HTTPRequest{RequestMethod: "POST", RequestURL: "https://example.com/", RequestSize: 10, Status: 400, Referer: "https://example.com/form"},
+			`{"severity":"WARNING","httpRequest":{"requestMethod":"POST","requestUrl":"https://example.com/","status":400,"responseSize":"0","userAgent":"","remoteIp":"","latency":"0.000000000s","protocol":"","requestSize":"10","referer":"https://example.com/form"},"message":"POST https://example.com/ 400"}`,
+		},
+		{
+			&HTTPRequest{RequestMethod: "GET", RequestURL: "https://example.com/", Status: 503},
+			`{"severity":"ERROR","httpRequest":{"requestMethod":"GET","requestUrl":"https://example.com/","status":503,"responseSize":"0","userAgent":"","remoteIp":"","latency":"0.000000000s","protocol":""},"message":"GET https://example.com/ 503"}`,
+		},
+	} {
+		buffer = &bytes.Buffer{}
+		SetSharedLogger(buffer, true, false)
+		logger := NewLogger(GetSharedLogger())
+
+		logger.LogHTTPRequest(tt.req)
+		output := strings.TrimRight(buffer.String(), "\n")
+		if output != tt.want {
+			t.Errorf("LogHTTPRequest(%v) = %q, want = %q", tt.req, output, tt.want)
+		}
+	}
+}
+
+func TestLogGRPCRequest(t *testing.T) {
+	for _, tt := range []struct {
+		req  *GRPCRequest
+		want string
+	}{
+		{
+			&GRPCRequest{Method: "/TestService/Method", Code: codes.OK, Latency: 1500 * time.Millisecond, PeerAddress: "192.0.2.1:50000", RequestSize: 10, ResponseSize: 20},
+			`{"severity":"INFO","grpcRequest":{"method":"/TestService/Method","code":"OK","latency":"1.500000000s","peerAddress":"192.0.2.1:50000","requestSize":"10","responseSize":"20"},"message":"/TestService/Method OK"}`,
+		},
+		{
+			&GRPCRequest{Method: "/TestService/Method", Code: codes.InvalidArgument},
+			`{"severity":"WARNING","grpcRequest":{"method":"/TestService/Method","code":"InvalidArgument","latency":"0.000000000s","peerAddress":"","requestSize":"0","responseSize":"0"},"message":"/TestService/Method InvalidArgument"}`,
+		},
+		{
+			&GRPCRequest{Method: "/TestService/Method", Code: codes.Unavailable},
+			`{"severity":"ERROR","grpcRequest":{"method":"/TestService/Method","code":"Unavailable","latency":"0.000000000s","peerAddress":"","requestSize":"0","responseSize":"0"},"message":"/TestService/Method Unavailable"}`,
+		},
+	} {
+		buffer = &bytes.Buffer{}
+		SetSharedLogger(buffer, true, false)
+		logger := NewLogger(GetSharedLogger())
+
+		logger.LogGRPCRequest(tt.req)
+		output := strings.TrimRight(buffer.String(), "\n")
+		if output != tt.want {
+			t.Errorf("LogGRPCRequest(%v) = %q, want = %q", tt.req, output, tt.want)
+		}
+	}
+}
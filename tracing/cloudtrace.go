@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	cloudtrace "cloud.google.com/go/trace/apiv2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/option"
+	cloudtracepb "google.golang.org/genproto/googleapis/devtools/cloudtrace/v2"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// For limits, see https://cloud.google.com/trace/docs/reference/v2/rpc/google.devtools.cloudtrace.v2#google.devtools.cloudtrace.v2.Span
+const (
+	maxDisplayNameBytes    = 128
+	maxAttributeKeyBytes   = 128
+	maxAttributeValueBytes = 256
+)
+
+// CloudTraceExporter exports spans to Cloud Trace by BatchWriteSpans API.
+type CloudTraceExporter struct {
+	projectID string
+	client    *cloudtrace.Client
+}
+
+var _ sdktrace.SpanExporter = (*CloudTraceExporter)(nil)
+
+// NewCloudTraceExporter creates CloudTraceExporter writing spans to projectID.
+// On Cloud Run, the credentials of the service account are used by default.
+func NewCloudTraceExporter(ctx context.Context, projectID string, opts ...option.ClientOption) (*CloudTraceExporter, error) {
+	client, err := cloudtrace.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Trace client: %w", err)
+	}
+
+	return &CloudTraceExporter{projectID: projectID, client: client}, nil
+}
+
+func (e *CloudTraceExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	req := &cloudtracepb.BatchWriteSpansRequest{
+		Name:  "projects/" + e.projectID,
+		Spans: make([]*cloudtracepb.Span, 0, len(spans)),
+	}
+	for _, span := range spans {
+		req.Spans = append(req.Spans, toCloudTraceSpan(e.projectID, span))
+	}
+
+	if err := e.client.BatchWriteSpans(ctx, req); err != nil {
+		return fmt.Errorf("failed to write spans to Cloud Trace: %w", err)
+	}
+
+	return nil
+}
+
+func (e *CloudTraceExporter) Shutdown(ctx context.Context) error {
+	return e.client.Close()
+}
+
+func toCloudTraceSpan(projectID string, span sdktrace.ReadOnlySpan) *cloudtracepb.Span {
+	sc := span.SpanContext()
+
+	s := &cloudtracepb.Span{
+		Name:        fmt.Sprintf("projects/%s/traces/%s/spans/%s", projectID, sc.TraceID(), sc.SpanID()),
+		SpanId:      sc.SpanID().String(),
+		DisplayName: truncatableString(span.Name(), maxDisplayNameBytes),
+		StartTime:   timestamppb.New(span.StartTime()),
+		EndTime:     timestamppb.New(span.EndTime()),
+		SpanKind:    toCloudTraceSpanKind(span.SpanKind()),
+	}
+
+	if parent := span.Parent(); parent.IsValid() {
+		s.ParentSpanId = parent.SpanID().String()
+		s.SameProcessAsParentSpan = wrapperspb.Bool(!parent.IsRemote())
+	}
+
+	if attrs := span.Attributes(); len(attrs) > 0 {
+		s.Attributes = &cloudtracepb.Span_Attributes{
+			AttributeMap:           make(map[string]*cloudtracepb.AttributeValue, len(attrs)),
+			DroppedAttributesCount: int32(span.DroppedAttributes()),
+		}
+		for _, attr := range attrs {
+			key := truncate(string(attr.Key), maxAttributeKeyBytes)
+			s.Attributes.AttributeMap[key] = toCloudTraceAttributeValue(attr.Value)
+		}
+	}
+
+	if status := span.Status(); status.Code == codes.Error {
+		s.Status = &statuspb.Status{Code: int32(grpccodes.Unknown), Message: status.Description}
+	}
+
+	return s
+}
+
+func toCloudTraceSpanKind(kind trace.SpanKind) cloudtracepb.Span_SpanKind {
+	switch kind {
+	case trace.SpanKindInternal:
+		return cloudtracepb.Span_INTERNAL
+	case trace.SpanKindServer:
+		return cloudtracepb.Span_SERVER
+	case trace.SpanKindClient:
+		return cloudtracepb.Span_CLIENT
+	case trace.SpanKindProducer:
+		return cloudtracepb.Span_PRODUCER
+	case trace.SpanKindConsumer:
+		return cloudtracepb.Span_CONSUMER
+	default:
+		return cloudtracepb.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
+
+// Cloud Trace supports only string, int and bool values, so the others are formatted as string.
+func toCloudTraceAttributeValue(v attribute.Value) *cloudtracepb.AttributeValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &cloudtracepb.AttributeValue{Value: &cloudtracepb.AttributeValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &cloudtracepb.AttributeValue{Value: &cloudtracepb.AttributeValue_IntValue{IntValue: v.AsInt64()}}
+	default:
+		return &cloudtracepb.AttributeValue{
+			Value: &cloudtracepb.AttributeValue_StringValue{StringValue: truncatableString(v.Emit(), maxAttributeValueBytes)},
+		}
+	}
+}
+
+func truncatableString(s string, limit int) *cloudtracepb.TruncatableString {
+	truncated := truncate(s, limit)
+	return &cloudtracepb.TruncatableString{
+		Value:              truncated,
+		TruncatedByteCount: int32(len(s) - len(truncated)),
+	}
+}
+
+// truncate cuts s to limit bytes without breaking a multi-byte character.
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
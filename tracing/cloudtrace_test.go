@@ -0,0 +1,180 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/option"
+	cloudtracepb "google.golang.org/genproto/googleapis/devtools/cloudtrace/v2"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var (
+	testTraceID, _      = trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	testSpanID, _       = trace.SpanIDFromHex("b7ad6b7169203331")
+	testParentSpanID, _ = trace.SpanIDFromHex("00f067aa0ba902b7")
+	testStartTime       = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func testSpan() tracetest.SpanStub {
+	return tracetest.SpanStub{
+		Name: "/hello",
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    testTraceID,
+			SpanID:     testSpanID,
+			TraceFlags: trace.FlagsSampled,
+		}),
+		Parent: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    testTraceID,
+			SpanID:     testParentSpanID,
+			TraceFlags: trace.FlagsSampled,
+			Remote:     true,
+		}),
+		SpanKind:  trace.SpanKindServer,
+		StartTime: testStartTime,
+		EndTime:   testStartTime.Add(time.Second),
+		Attributes: []attribute.KeyValue{
+			attribute.String("http.method", "GET"),
+			attribute.Int("http.status_code", 500),
+			attribute.Bool("error", true),
+			attribute.Float64("ratio", 0.5),
+		},
+		Status: sdktrace.Status{Code: codes.Error, Description: "Internal Server Error"},
+	}
+}
+
+func testCloudTraceSpan() *cloudtracepb.Span {
+	return &cloudtracepb.Span{
+		Name:                    "projects/sample-google-project/traces/0af7651916cd43dd8448eb211c80319c/spans/b7ad6b7169203331",
+		SpanId:                  "b7ad6b7169203331",
+		ParentSpanId:            "00f067aa0ba902b7",
+		DisplayName:             &cloudtracepb.TruncatableString{Value: "/hello"},
+		StartTime:               timestamppb.New(testStartTime),
+		EndTime:                 timestamppb.New(testStartTime.Add(time.Second)),
+		SameProcessAsParentSpan: wrapperspb.Bool(false),
+		SpanKind:                cloudtracepb.Span_SERVER,
+		Attributes: &cloudtracepb.Span_Attributes{
+			AttributeMap: map[string]*cloudtracepb.AttributeValue{
+				"http.method": {Value: &cloudtracepb.AttributeValue_StringValue{
+					StringValue: &cloudtracepb.TruncatableString{Value: "GET"},
+				}},
+				"http.status_code": {Value: &cloudtracepb.AttributeValue_IntValue{IntValue: 500}},
+				"error":            {Value: &cloudtracepb.AttributeValue_BoolValue{BoolValue: true}},
+				"ratio": {Value: &cloudtracepb.AttributeValue_StringValue{
+					StringValue: &cloudtracepb.TruncatableString{Value: "0.5"},
+				}},
+			},
+		},
+		Status: &statuspb.Status{Code: 2, Message: "Internal Server Error"},
+	}
+}
+
+func TestToCloudTraceSpan(t *testing.T) {
+	root := testSpan()
+	root.Parent = trace.SpanContext{}
+	root.Attributes = nil
+	root.Status = sdktrace.Status{}
+	root.SpanKind = trace.SpanKindClient
+
+	wantRoot := testCloudTraceSpan()
+	wantRoot.ParentSpanId = ""
+	wantRoot.SameProcessAsParentSpan = nil
+	wantRoot.Attributes = nil
+	wantRoot.Status = nil
+	wantRoot.SpanKind = cloudtracepb.Span_CLIENT
+
+	tests := []struct {
+		span tracetest.SpanStub
+		want *cloudtracepb.Span
+	}{
+		{span: testSpan(), want: testCloudTraceSpan()},
+		{span: root, want: wantRoot},
+	}
+
+	for _, tt := range tests {
+		if got := toCloudTraceSpan("sample-google-project", tt.span.Snapshot()); !proto.Equal(tt.want, got) {
+			t.Errorf("wrong span %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestTruncatableString(t *testing.T) {
+	tests := []struct {
+		s     string
+		limit int
+		want  *cloudtracepb.TruncatableString
+	}{
+		{s: "hello", limit: 5, want: &cloudtracepb.TruncatableString{Value: "hello"}},
+		{s: "hello", limit: 3, want: &cloudtracepb.TruncatableString{Value: "hel", TruncatedByteCount: 2}},
+		// a multi-byte character is not broken
+		{s: "こんにちは", limit: 4, want: &cloudtracepb.TruncatableString{Value: "こ", TruncatedByteCount: 12}},
+		{s: strings.Repeat("a", 200), limit: maxDisplayNameBytes, want: &cloudtracepb.TruncatableString{
+			Value: strings.Repeat("a", 128), TruncatedByteCount: 72,
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := truncatableString(tt.s, tt.limit); !proto.Equal(tt.want, got) {
+			t.Errorf("wrong string %v, want %v", got, tt.want)
+		}
+	}
+}
+
+type fakeTraceServer struct {
+	cloudtracepb.UnimplementedTraceServiceServer
+	reqCh chan *cloudtracepb.BatchWriteSpansRequest
+}
+
+func (s *fakeTraceServer) BatchWriteSpans(ctx context.Context, req *cloudtracepb.BatchWriteSpansRequest) (*emptypb.Empty, error) {
+	s.reqCh <- req
+	return &emptypb.Empty{}, nil
+}
+
+func TestCloudTraceExporter(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fake := &fakeTraceServer{reqCh: make(chan *cloudtracepb.BatchWriteSpansRequest, 1)}
+	srv := grpc.NewServer()
+	cloudtracepb.RegisterTraceServiceServer(srv, fake)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	exporter, err := NewCloudTraceExporter(ctx, "sample-google-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer exporter.Shutdown(ctx)
+
+	if err := exporter.ExportSpans(ctx, tracetest.SpanStubs{testSpan()}.Snapshots()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &cloudtracepb.BatchWriteSpansRequest{
+		Name:  "projects/sample-google-project",
+		Spans: []*cloudtracepb.Span{testCloudTraceSpan()},
+	}
+	if got := <-fake.reqCh; !proto.Equal(want, got) {
+		t.Errorf("wrong request %v, want %v", got, want)
+	}
+}
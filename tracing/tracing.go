@@ -0,0 +1,110 @@
+// Package tracing creates OpenTelemetry spans in the HTTP and gRPC middlewares of the SDK.
+// Spans are recorded only after Setup is called, otherwise the trace context is just propagated as before.
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/allabout/cloud-run-sdk/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+const instrumentationName = "github.com/allabout/cloud-run-sdk"
+
+// Setup registers a TracerProvider which exports spans by exporter as the global TracerProvider.
+// exporter is any sdktrace.SpanExporter, e.g. CloudTraceExporter, the OTLP exporter of go.opentelemetry.io/otel/exporters/otlp/otlptrace
+// or tracetest.NewInMemoryExporter in tests.
+// Call Shutdown of the returned TracerProvider on shutdown to flush the buffered spans.
+func Setup(exporter sdktrace.SpanExporter, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	tp := sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithBatcher(exporter)}, opts...)...)
+	otel.SetTracerProvider(tp)
+
+	return tp
+}
+
+// Start starts a span as a child of the span in ctx, or parent if ctx doesn't have any span.
+// The returned SpanContext is the one of the new span to correlate logs and propagate to downstream.
+// If the span is not recorded, e.g. Setup is not called or parent is not sampled, parent is returned as it is.
+func Start(ctx context.Context, name string, parent *util.SpanContext, opts ...trace.SpanStartOption) (context.Context, trace.Span, *util.SpanContext) {
+	if parent != nil && !trace.SpanContextFromContext(ctx).IsValid() {
+		if remote, ok := toOtelSpanContext(parent); ok {
+			ctx = trace.ContextWithRemoteSpanContext(ctx, remote)
+		}
+	}
+
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+	if !span.IsRecording() {
+		return ctx, span, parent
+	}
+
+	sc := span.SpanContext()
+	child := &util.SpanContext{
+		TraceID: sc.TraceID().String(),
+		SpanID:  sc.SpanID().String(),
+		Sampled: sc.IsSampled(),
+	}
+	if parent != nil {
+		child.TraceState = parent.TraceState
+	}
+
+	return ctx, span, child
+}
+
+// EndHTTP records the status code of the HTTP response and ends span.
+func EndHTTP(span trace.Span, code int) {
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(code)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(code))
+	span.End()
+}
+
+// EndGRPC records the status code of err returned by the RPC and ends span.
+func EndGRPC(span trace.Span, err error) {
+	s := status.Convert(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int64(int64(s.Code())))
+	if err != nil {
+		span.SetStatus(codes.Error, s.Message())
+	}
+	span.End()
+}
+
+// GRPCAttributes returns the attributes of the RPC named fullMethod, e.g. /grpc.health.v1.Health/Check.
+func GRPCAttributes(fullMethod string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.RPCSystemKey.String("grpc")}
+
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		attrs = append(attrs, semconv.RPCServiceKey.String(name[:i]), semconv.RPCMethodKey.String(name[i+1:]))
+	}
+
+	return attrs
+}
+
+func toOtelSpanContext(sc *util.SpanContext) (trace.SpanContext, bool) {
+	traceID, err := trace.TraceIDFromHex(strings.ToLower(sc.TraceID))
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	spanID, err := trace.SpanIDFromHex(sc.SpanID)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+
+	var flags trace.TraceFlags
+	if sc.Sampled {
+		flags = trace.FlagsSampled
+	}
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	}), true
+}
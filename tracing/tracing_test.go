@@ -0,0 +1,236 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/allabout/cloud-run-sdk/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// keepingExporter keeps the spans after Shutdown, which are cleared by tracetest.InMemoryExporter.
+type keepingExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (e *keepingExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// setupInMemory enables tracing until the test finishes, and returns the function to get the exported spans.
+func setupInMemory(t *testing.T) func() tracetest.SpanStubs {
+	exporter := &keepingExporter{tracetest.NewInMemoryExporter()}
+	tp := Setup(exporter)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+
+	return func() tracetest.SpanStubs {
+		// Shutdown flushes all the spans ended so far
+		if err := tp.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return exporter.GetSpans()
+	}
+}
+
+func TestStartWithoutSetup(t *testing.T) {
+	parent := &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true}
+
+	_, span, sc := Start(context.Background(), "test", parent)
+	span.End()
+
+	if span.IsRecording() {
+		t.Error("span must not be recorded")
+	}
+	if sc != parent {
+		t.Errorf("wrong span context %#v, want %#v", sc, parent)
+	}
+}
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		parent       *util.SpanContext
+		wantRecorded bool
+		wantSameTree bool
+	}{
+		{
+			parent:       &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true, TraceState: "foo=bar"},
+			wantRecorded: true,
+			wantSameTree: true,
+		},
+		{
+			// the sampling decision of the caller is respected
+			parent:       &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: false},
+			wantRecorded: false,
+		},
+		{
+			// the trace id can't be represented by OpenTelemetry, so a new trace is started
+			parent:       &util.SpanContext{TraceID: "0123456789abcdef", SpanID: "000000000000007b", Sampled: true},
+			wantRecorded: true,
+			wantSameTree: false,
+		},
+		{
+			parent:       nil,
+			wantRecorded: true,
+			wantSameTree: false,
+		},
+	}
+
+	for _, tt := range tests {
+		getSpans := setupInMemory(t)
+
+		_, span, sc := Start(context.Background(), "test", tt.parent, trace.WithSpanKind(trace.SpanKindServer))
+		span.End()
+
+		spans := getSpans()
+		if !tt.wantRecorded {
+			if len(spans) != 0 {
+				t.Errorf("span must not be recorded, got %d spans", len(spans))
+			}
+			if sc != tt.parent {
+				t.Errorf("wrong span context %#v, want %#v", sc, tt.parent)
+			}
+			continue
+		}
+
+		if len(spans) != 1 {
+			t.Fatalf("wrong number of spans %d, want 1", len(spans))
+		}
+		got := spans[0]
+
+		if want := (&util.SpanContext{
+			TraceID:    got.SpanContext.TraceID().String(),
+			SpanID:     got.SpanContext.SpanID().String(),
+			Sampled:    true,
+			TraceState: sc.TraceState,
+		}); !reflect.DeepEqual(want, sc) {
+			t.Errorf("wrong span context %#v, want %#v", sc, want)
+		}
+		if got.SpanKind != trace.SpanKindServer {
+			t.Errorf("wrong span kind %v, want %v", got.SpanKind, trace.SpanKindServer)
+		}
+
+		if !tt.wantSameTree {
+			if got.Parent.IsValid() {
+				t.Errorf("span must be root, got parent %v", got.Parent.SpanID())
+			}
+			continue
+		}
+		if want, got := tt.parent.TraceID, sc.TraceID; want != got {
+			t.Errorf("wrong trace id %s, want %s", got, want)
+		}
+		if want, got := tt.parent.SpanID, got.Parent.SpanID().String(); want != got {
+			t.Errorf("wrong parent span id %s, want %s", got, want)
+		}
+		if want, got := tt.parent.TraceState, sc.TraceState; want != got {
+			t.Errorf("wrong trace state %s, want %s", got, want)
+		}
+	}
+}
+
+func TestStartWithSpanInContext(t *testing.T) {
+	getSpans := setupInMemory(t)
+
+	parent := &util.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true}
+	ctx, server, serverSC := Start(context.Background(), "server", parent)
+	// the span in ctx is preferred to parent
+	_, client, _ := Start(ctx, "client", parent)
+	client.End()
+	server.End()
+
+	spans := getSpans()
+	if len(spans) != 2 {
+		t.Fatalf("wrong number of spans %d, want 2", len(spans))
+	}
+	if want, got := serverSC.SpanID, spans[0].Parent.SpanID().String(); want != got {
+		t.Errorf("wrong parent span id %s, want %s", got, want)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	tests := []struct {
+		end        func(trace.Span)
+		wantAttr   attribute.KeyValue
+		wantStatus codes.Code
+	}{
+		{
+			end:        func(span trace.Span) { EndHTTP(span, 200) },
+			wantAttr:   semconv.HTTPStatusCodeKey.Int(200),
+			wantStatus: codes.Unset,
+		},
+		{
+			end:        func(span trace.Span) { EndHTTP(span, 503) },
+			wantAttr:   semconv.HTTPStatusCodeKey.Int(503),
+			wantStatus: codes.Error,
+		},
+		{
+			end:        func(span trace.Span) { EndGRPC(span, nil) },
+			wantAttr:   semconv.RPCGRPCStatusCodeKey.Int64(0),
+			wantStatus: codes.Unset,
+		},
+		{
+			end:        func(span trace.Span) { EndGRPC(span, status.Error(grpccodes.NotFound, "not found")) },
+			wantAttr:   semconv.RPCGRPCStatusCodeKey.Int64(int64(grpccodes.NotFound)),
+			wantStatus: codes.Error,
+		},
+		{
+			end:        func(span trace.Span) { EndGRPC(span, errors.New("unknown")) },
+			wantAttr:   semconv.RPCGRPCStatusCodeKey.Int64(int64(grpccodes.Unknown)),
+			wantStatus: codes.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		getSpans := setupInMemory(t)
+
+		_, span, _ := Start(context.Background(), "test", nil)
+		tt.end(span)
+
+		spans := getSpans()
+		if len(spans) != 1 {
+			t.Fatalf("wrong number of spans %d, want 1", len(spans))
+		}
+		if want, got := []attribute.KeyValue{tt.wantAttr}, spans[0].Attributes; !reflect.DeepEqual(want, got) {
+			t.Errorf("wrong attributes %v, want %v", got, want)
+		}
+		if want, got := tt.wantStatus, spans[0].Status.Code; want != got {
+			t.Errorf("wrong status %v, want %v", got, want)
+		}
+	}
+}
+
+func TestGRPCAttributes(t *testing.T) {
+	tests := []struct {
+		fullMethod string
+		want       []attribute.KeyValue
+	}{
+		{
+			fullMethod: "/grpc.health.v1.Health/Check",
+			want: []attribute.KeyValue{
+				semconv.RPCSystemKey.String("grpc"),
+				semconv.RPCServiceKey.String("grpc.health.v1.Health"),
+				semconv.RPCMethodKey.String("Check"),
+			},
+		},
+		{
+			fullMethod: "invalid",
+			want:       []attribute.KeyValue{semconv.RPCSystemKey.String("grpc")},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := GRPCAttributes(tt.fullMethod); !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("wrong attributes %v, want %v", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"cloud.google.com/go/compute/metadata"
+)
+
+// IDTokenEnv is the environment variable of the ID token used outside Google Cloud,
+// e.g. export ID_TOKEN=$(gcloud auth print-identity-token).
+const IDTokenEnv = "ID_TOKEN"
+
+// IdentityProvider issues ID tokens to call the services of audience.
+type IdentityProvider interface {
+	IDToken(ctx context.Context, audience string) (string, error)
+}
+
+// IdentityProviderFunc is an adapter to use ordinary functions as IdentityProvider.
+type IdentityProviderFunc func(ctx context.Context, audience string) (string, error)
+
+func (f IdentityProviderFunc) IDToken(ctx context.Context, audience string) (string, error) {
+	return f(ctx, audience)
+}
+
+// MetadataIdentityProvider issues ID tokens of the service account by the metadata server, which is used on Cloud Run.
+type MetadataIdentityProvider struct{}
+
+func (MetadataIdentityProvider) IDToken(ctx context.Context, audience string) (string, error) {
+	return fetchIDTokenFromMetadata(ctx, audience)
+}
+
+// StaticIdentityProvider returns the same token for any audience, e.g. the output of `gcloud auth print-identity-token`.
+type StaticIdentityProvider string
+
+func (p StaticIdentityProvider) IDToken(ctx context.Context, audience string) (string, error) {
+	return string(p), nil
+}
+
+// EnvIdentityProvider reads the token from the environment variable of the name every time, so it can be updated while running.
+type EnvIdentityProvider string
+
+func (p EnvIdentityProvider) IDToken(ctx context.Context, audience string) (string, error) {
+	token := os.Getenv(string(p))
+	if token == "" {
+		return "", fmt.Errorf("environment variable %s is not set", string(p))
+	}
+	return token, nil
+}
+
+// InsecureIdentityProvider doesn't issue ID tokens, which is for local development against servers without authentication.
+// The clients send requests without Authorization header, while the connections are still encrypted.
+type InsecureIdentityProvider struct{}
+
+func (InsecureIdentityProvider) IDToken(ctx context.Context, audience string) (string, error) {
+	return "", nil
+}
+
+var (
+	identityProviderMu sync.RWMutex
+	identityProvider   IdentityProvider
+)
+
+// SetIdentityProvider replaces the provider selected by GetIdentityProvider. nil restores the automatic selection.
+func SetIdentityProvider(p IdentityProvider) {
+	identityProviderMu.Lock()
+	defer identityProviderMu.Unlock()
+
+	identityProvider = p
+}
+
+// GetIdentityProvider returns the provider set by SetIdentityProvider, or selects it from the environment:
+// MetadataIdentityProvider on Cloud Run, EnvIdentityProvider if ID_TOKEN is set,
+// MetadataIdentityProvider if the metadata server is available, e.g. GCE, GKE and GCE_METADATA_HOST,
+// and InsecureIdentityProvider otherwise.
+func GetIdentityProvider() IdentityProvider {
+	identityProviderMu.RLock()
+	defer identityProviderMu.RUnlock()
+
+	if identityProvider != nil {
+		return identityProvider
+	}
+
+	switch {
+	case IsCloudRun():
+		return MetadataIdentityProvider{}
+	case os.Getenv(IDTokenEnv) != "":
+		return EnvIdentityProvider(IDTokenEnv)
+	case metadata.OnGCE():
+		return MetadataIdentityProvider{}
+	default:
+		return InsecureIdentityProvider{}
+	}
+}
+
+// IsInsecure reports whether p is InsecureIdentityProvider.
+func IsInsecure(p IdentityProvider) bool {
+	_, ok := p.(InsecureIdentityProvider)
+	return ok
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// setenv sets the environment variable until the test finishes. Empty value unsets it.
+func setenv(t *testing.T, key, value string) {
+	old, isSet := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+	t.Cleanup(func() {
+		if isSet {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestIdentityProviders(t *testing.T) {
+	setenv(t, "TEST_ID_TOKEN", "env-token")
+	setenv(t, "TEST_EMPTY_ID_TOKEN", "")
+
+	tests := []struct {
+		name     string
+		provider IdentityProvider
+		want     string
+		wantErr  bool
+	}{
+		{name: "static", provider: StaticIdentityProvider("static-token"), want: "static-token"},
+		{name: "env", provider: EnvIdentityProvider("TEST_ID_TOKEN"), want: "env-token"},
+		{name: "env not set", provider: EnvIdentityProvider("TEST_EMPTY_ID_TOKEN"), wantErr: true},
+		{name: "insecure", provider: InsecureIdentityProvider{}, want: ""},
+		{name: "func", provider: IdentityProviderFunc(func(ctx context.Context, audience string) (string, error) {
+			return "token-for-" + audience, nil
+		}), want: "token-for-https://example.a.run.app"},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.provider.IDToken(context.Background(), "https://example.a.run.app")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: IDToken() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetIdentityProvider(t *testing.T) {
+	tests := []struct {
+		name            string
+		kConfiguration  string
+		idToken         string
+		gceMetadataHost string
+		want            IdentityProvider
+	}{
+		{name: "cloud run", kConfiguration: "true", idToken: "env-token", want: MetadataIdentityProvider{}},
+		{name: "env", idToken: "env-token", gceMetadataHost: "localhost:8888", want: EnvIdentityProvider(IDTokenEnv)},
+		{name: "metadata server", gceMetadataHost: "localhost:8888", want: MetadataIdentityProvider{}},
+	}
+
+	for _, tt := range tests {
+		setenv(t, "K_CONFIGURATION", tt.kConfiguration)
+		setenv(t, IDTokenEnv, tt.idToken)
+		setenv(t, "GCE_METADATA_HOST", tt.gceMetadataHost)
+
+		if got := GetIdentityProvider(); !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%s: GetIdentityProvider() = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+
+	// the provider set explicitly is preferred
+	SetIdentityProvider(InsecureIdentityProvider{})
+	defer SetIdentityProvider(nil)
+	if got := GetIdentityProvider(); !IsInsecure(got) {
+		t.Errorf("GetIdentityProvider() = %#v, want InsecureIdentityProvider", got)
+	}
+}
+
+func TestIDTokenSourceWithInsecureProvider(t *testing.T) {
+	token, err := NewIDTokenSourceWithProvider("https://example.a.run.app", InsecureIdentityProvider{}).Token(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if token != "" {
+		t.Errorf("Token() = %q, want empty", token)
+	}
+}
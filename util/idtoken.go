@@ -0,0 +1,184 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IDTokenRefreshMargin is how long before the expiry the cached ID token is refreshed,
+// which covers the clock skew and the latency until the callee verifies it.
+const IDTokenRefreshMargin = 5 * time.Minute
+
+// idTokenFetchTimeout bounds the refresh, which is detached from the callers' context and shared by them.
+const idTokenFetchTimeout = 30 * time.Second
+
+// IDTokenSource provides the ID token for the audience, which is cached and refreshed before it expires.
+// It is safe for concurrent use.
+type IDTokenSource struct {
+	audience string
+	provider IdentityProvider
+	now      func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+	// refresh is the in-flight refresh, which is nil if none
+	refresh *idTokenRefresh
+}
+
+// idTokenRefresh is the result of a refresh shared by the callers waiting for it.
+type idTokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// NewIDTokenSource creates IDTokenSource fetching the ID token by GetIdentityProvider, e.g. audience is https://xxx.a.run.app.
+func NewIDTokenSource(audience string) *IDTokenSource {
+	return NewIDTokenSourceWithProvider(audience, GetIdentityProvider())
+}
+
+// NewIDTokenSourceWithProvider creates IDTokenSource fetching the ID token by provider.
+func NewIDTokenSourceWithProvider(audience string, provider IdentityProvider) *IDTokenSource {
+	return &IDTokenSource{
+		audience: audience,
+		provider: provider,
+		now:      time.Now,
+	}
+}
+
+// Token returns the cached ID token, or fetches new one if there is no valid token.
+// If the cached token expires within IDTokenRefreshMargin, it is returned while refreshed in the background,
+// so the callers don't wait for the metadata server.
+// It returns empty string with InsecureIdentityProvider.
+func (s *IDTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+
+	now := s.now()
+	if s.token != "" && now.Before(s.expiry) {
+		token := s.token
+		if !now.Add(IDTokenRefreshMargin).Before(s.expiry) {
+			s.startRefresh()
+		}
+		s.mu.Unlock()
+		return token, nil
+	}
+
+	r := s.startRefresh()
+	s.mu.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return "", fmt.Errorf("failed to fetch ID token: %w", ctx.Err())
+	}
+	if r.err != nil {
+		return "", fmt.Errorf("failed to fetch ID token: %w", r.err)
+	}
+
+	return r.token, nil
+}
+
+// startRefresh starts fetching the token unless it's in flight. It must be called with s.mu held.
+func (s *IDTokenSource) startRefresh() *idTokenRefresh {
+	if s.refresh != nil {
+		return s.refresh
+	}
+
+	r := &idTokenRefresh{done: make(chan struct{})}
+	s.refresh = r
+
+	go func() {
+		// the fetch is shared, so it's not cancelled by any of the callers
+		ctx, cancel := context.WithTimeout(context.Background(), idTokenFetchTimeout)
+		defer cancel()
+
+		token, err := s.provider.IDToken(ctx, s.audience)
+
+		s.mu.Lock()
+		if err == nil {
+			// the token can't be cached without the expiry, so it is fetched every time
+			if expiry, err := idTokenExpiry(token); err == nil {
+				s.token, s.expiry = token, expiry
+			}
+		}
+		r.token, r.err = token, err
+		s.refresh = nil
+		s.mu.Unlock()
+
+		close(r.done)
+	}()
+
+	return r
+}
+
+// fetchIDTokenFromMetadata requests the metadata server in the same way as metadata.Get, which doesn't take ctx.
+func fetchIDTokenFromMetadata(ctx context.Context, audience string) (string, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "169.254.169.254"
+	}
+
+	u := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/identity?audience=" + url.QueryEscape(audience)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read metadata response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %d: %s", resp.StatusCode, body)
+	}
+
+	return string(body), nil
+}
+
+// idTokenExpiry returns exp claim of the JWT. The signature is not verified, since the token is issued for us.
+func idTokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("ID token is not JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode ID token payload: %w", err)
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse ID token payload: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, errors.New("ID token doesn't have exp claim")
+	}
+
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// AudienceFromAddr returns the audience of the Cloud Run service served at addr, e.g. xxx.a.run.app:443.
+func AudienceFromAddr(addr string) string {
+	return fmt.Sprintf("https://%s", strings.Split(addr, ":")[0])
+}
@@ -0,0 +1,235 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestIDToken(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"aud":"https://example.a.run.app","exp":%d}`, exp.Unix())))
+	return "eyJhbGciOiJSUzI1NiJ9." + payload + ".signature"
+}
+
+func TestIDTokenSource(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	cached := newTestIDToken(now.Add(time.Hour))
+	refreshed := newTestIDToken(now.Add(2 * time.Hour))
+
+	tests := []struct {
+		name      string
+		elapsed   time.Duration
+		fetchErr  error
+		wantToken string
+		// the token after the background refresh completes
+		wantRefreshed string
+		wantErr       bool
+	}{
+		{name: "cached", elapsed: 30 * time.Minute, wantToken: cached, wantRefreshed: cached},
+		{name: "refreshed before expiry", elapsed: 56 * time.Minute, wantToken: cached, wantRefreshed: refreshed},
+		{name: "refreshed after expiry", elapsed: 2 * time.Hour, wantToken: refreshed, wantRefreshed: refreshed},
+		{name: "cached token is still valid", elapsed: 56 * time.Minute, fetchErr: errors.New("unavailable"), wantToken: cached, wantRefreshed: cached},
+		{name: "cached token is expired", elapsed: time.Hour, fetchErr: errors.New("unavailable"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var fetched int
+		clock := now
+		s := &IDTokenSource{
+			audience: "https://example.a.run.app",
+			provider: IdentityProviderFunc(func(ctx context.Context, audience string) (string, error) {
+				fetched++
+				if fetched == 1 {
+					return cached, nil
+				}
+				if tt.fetchErr != nil {
+					return "", tt.fetchErr
+				}
+				return refreshed, nil
+			}),
+			now: func() time.Time { return clock },
+		}
+
+		if _, err := s.Token(context.Background()); err != nil {
+			t.Fatalf("%s: Unexpected error: %v", tt.name, err)
+		}
+
+		clock = now.Add(tt.elapsed)
+		token, err := s.Token(context.Background())
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: error is expected", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", tt.name, err)
+		}
+		if want, got := tt.wantToken, token; want != got {
+			t.Errorf("%s: wrong token %q, want %q", tt.name, got, want)
+		}
+
+		waitRefresh(s)
+		token, err = s.Token(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Unexpected error: %v", tt.name, err)
+		}
+		if want, got := tt.wantRefreshed, token; want != got {
+			t.Errorf("%s: wrong token after refresh %q, want %q", tt.name, got, want)
+		}
+		// the refresh started by the last call uses tt
+		waitRefresh(s)
+	}
+}
+
+// waitRefresh waits for the in-flight refresh of s.
+func waitRefresh(s *IDTokenSource) {
+	s.mu.Lock()
+	r := s.refresh
+	s.mu.Unlock()
+
+	if r != nil {
+		<-r.done
+	}
+}
+
+func TestIDTokenSourceDoesNotWait(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	cached := newTestIDToken(now.Add(time.Hour))
+
+	// the refresh hangs until the test ends
+	hang := make(chan struct{})
+	defer close(hang)
+
+	var fetched int
+	clock := now
+	s := &IDTokenSource{
+		provider: IdentityProviderFunc(func(ctx context.Context, audience string) (string, error) {
+			fetched++
+			if fetched == 1 {
+				return cached, nil
+			}
+			<-hang
+			return "", errors.New("unavailable")
+		}),
+		now: func() time.Time { return clock },
+	}
+
+	if _, err := s.Token(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// the cached token is returned while the refresh hangs
+	clock = now.Add(56 * time.Minute)
+	for i := 0; i < 2; i++ {
+		token, err := s.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if token != cached {
+			t.Errorf("wrong token %q, want %q", token, cached)
+		}
+	}
+
+	// the caller waiting for the token can cancel it
+	clock = now.Add(2 * time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Token(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestFetchIDTokenFromMetadataWithContext(t *testing.T) {
+	hang := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer srv.Close()
+	defer close(hang)
+
+	old, isSet := os.LookupEnv("GCE_METADATA_HOST")
+	os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(srv.URL, "http://"))
+	defer func() {
+		if isSet {
+			os.Setenv("GCE_METADATA_HOST", old)
+		} else {
+			os.Unsetenv("GCE_METADATA_HOST")
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fetchIDTokenFromMetadata(ctx, "https://example.a.run.app"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestIDTokenSourceWithoutExpiry(t *testing.T) {
+	var fetched int
+	s := &IDTokenSource{
+		provider: IdentityProviderFunc(func(ctx context.Context, audience string) (string, error) {
+			fetched++
+			return "opaque-token", nil
+		}),
+		now: time.Now,
+	}
+
+	for i := 0; i < 2; i++ {
+		token, err := s.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := "opaque-token"; token != want {
+			t.Errorf("wrong token %q, want %q", token, want)
+		}
+	}
+	// the token without expiry is not cached
+	if want := 2; fetched != want {
+		t.Errorf("fetched %d times, want %d", fetched, want)
+	}
+}
+
+func TestIDTokenExpiry(t *testing.T) {
+	exp := time.Unix(1622505600, 0)
+
+	tests := []struct {
+		token   string
+		want    time.Time
+		wantErr bool
+	}{
+		{token: newTestIDToken(exp), want: exp},
+		{token: "opaque-token", wantErr: true},
+		{token: "header.!!!.signature", wantErr: true},
+		{token: "header." + base64.RawURLEncoding.EncodeToString([]byte(`{"aud":"x"}`)) + ".signature", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := idTokenExpiry(tt.token)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("idTokenExpiry(%q) error = %v, wantErr %v", tt.token, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("idTokenExpiry(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestAudienceFromAddr(t *testing.T) {
+	for addr, want := range map[string]string{
+		"example.a.run.app:443": "https://example.a.run.app",
+		"example.a.run.app":     "https://example.a.run.app",
+	} {
+		if got := AudienceFromAddr(addr); got != want {
+			t.Errorf("AudienceFromAddr(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
@@ -6,10 +6,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 
-	"cloud.google.com/go/compute/metadata"
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"google.golang.org/api/googleapi"
 	"google.golang.org/api/run/v1"
@@ -89,11 +87,10 @@ func IsCloudRun() bool {
 	return os.Getenv("K_CONFIGURATION") != ""
 }
 
+// GetIDToken fetches new ID token for the service served at addr by GetIdentityProvider every time.
+// Use IDTokenSource to reuse the token until it expires.
 func GetIDToken(addr string) (string, error) {
-	serviceURL := fmt.Sprintf("https://%s", strings.Split(addr, ":")[0])
-	tokenURL := fmt.Sprintf("/instance/service-accounts/default/identity?audience=%s", serviceURL)
-
-	return metadata.Get(tokenURL)
+	return GetIdentityProvider().IDToken(context.Background(), AudienceFromAddr(addr))
 }
 
 func FetchSecretLatestVersion(ctx context.Context, name, projectID string) (string, error) {
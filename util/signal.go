@@ -1,22 +1,52 @@
 package util
 
 import (
+	"context"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 )
 
 var (
 	shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
-	sigCh           = make(chan os.Signal, 2)
+
+	// sigChs holds channels registered by SetupSignalHandler and SetupSignalContext,
+	// so that they can be called multiple times and InjectSignal reaches all of them.
+	sigChsMu sync.Mutex
+	sigChs   []chan os.Signal
 )
 
+func notifySignal() chan os.Signal {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, shutdownSignals...)
+
+	sigChsMu.Lock()
+	sigChs = append(sigChs, sigCh)
+	sigChsMu.Unlock()
+
+	return sigCh
+}
+
+func stopSignal(sigCh chan os.Signal) {
+	signal.Stop(sigCh)
+
+	sigChsMu.Lock()
+	defer sigChsMu.Unlock()
+	for i, ch := range sigChs {
+		if ch == sigCh {
+			sigChs = append(sigChs[:i], sigChs[i+1:]...)
+			break
+		}
+	}
+}
+
 // SetupSignalHandler registers for SIGTERM and SIGINT. A stop channel is returned
 // which is closed on one of these signals. If a second signal is caught, the program
 // is terminated with exit code 1.
 func SetupSignalHandler() (stopCh <-chan struct{}) {
 	stop := make(chan struct{})
-	signal.Notify(sigCh, shutdownSignals...)
+	sigCh := notifySignal()
 	go func() {
 		<-sigCh
 		close(stop)
@@ -27,6 +57,108 @@ func SetupSignalHandler() (stopCh <-chan struct{}) {
 	return stop
 }
 
+type signalKey struct{}
+
+// signalContext records the signal which cancelled the context.
+type signalContext struct {
+	context.Context
+	mu  sync.Mutex
+	sig os.Signal
+}
+
+func (c *signalContext) Value(key interface{}) interface{} {
+	if _, ok := key.(signalKey); ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.sig
+	}
+	return c.Context.Value(key)
+}
+
+// SetupSignalContext registers for SIGTERM and SIGINT. A context is returned
+// which is cancelled on one of these signals, when parent is done or when the returned cancel is called.
+// The caught signal can be retrieved by SignalFromContext.
+// If exitOnSecondSignal is true and a second signal is caught, the program is terminated with exit code 1,
+// otherwise the further signals are ignored until cancel is called.
+// Calling cancel releases the registration, so it should be called as soon as the context is no longer needed.
+func SetupSignalContext(parent context.Context, exitOnSecondSignal bool) (context.Context, context.CancelFunc) {
+	return setupSignalContext(parent, notifySignal(), exitOnSecondSignal)
+}
+
+func setupSignalContext(parent context.Context, sigCh chan os.Signal, exitOnSecondSignal bool) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	sigCtx := &signalContext{Context: ctx}
+
+	// released is closed by the returned cancel, which releases the registration
+	released := make(chan struct{})
+	var once sync.Once
+	release := func() {
+		once.Do(func() { close(released) })
+		cancel()
+	}
+
+	go func() {
+		defer stopSignal(sigCh)
+
+		caught := false
+		select {
+		case sig := <-sigCh:
+			sigCtx.mu.Lock()
+			sigCtx.sig = sig
+			sigCtx.mu.Unlock()
+			cancel()
+			caught = true
+		case <-ctx.Done():
+		}
+
+		// the registration is kept until released, because the signals after signal.Stop terminate the program by default
+		for {
+			select {
+			case <-sigCh:
+				if exitOnSecondSignal && caught {
+					os.Exit(1) // second signal. Exit directly.
+				}
+				caught = true
+			case <-released:
+				return
+			}
+		}
+	}()
+
+	return sigCtx, release
+}
+
+// SignalFromContext returns the signal which cancelled the context created by SetupSignalContext.
+// It returns nil if no signal has been caught.
+func SignalFromContext(ctx context.Context) os.Signal {
+	sig, _ := ctx.Value(signalKey{}).(os.Signal)
+	return sig
+}
+
+// ContextWithStopCh returns a context which is cancelled when stopCh is closed.
+// It bridges the stop channel returned by SetupSignalHandler to context-based APIs.
+func ContextWithStopCh(stopCh <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// InjectSignal sends sig to all the handlers registered by SetupSignalHandler and SetupSignalContext.
 func InjectSignal(sig os.Signal) {
-	sigCh <- sig
+	sigChsMu.Lock()
+	defer sigChsMu.Unlock()
+
+	for _, sigCh := range sigChs {
+		select {
+		case sigCh <- sig:
+		default:
+		}
+	}
 }
@@ -1,9 +1,13 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestSetupSignalHandler(t *testing.T) {
@@ -14,3 +18,117 @@ func TestSetupSignalHandler(t *testing.T) {
 	}()
 	InjectSignal(os.Interrupt)
 }
+
+func TestSetupSignalContext(t *testing.T) {
+	sigCh := make(chan os.Signal, 2)
+	ctx, cancel := setupSignalContext(context.Background(), sigCh, false)
+	defer cancel()
+
+	if sig := SignalFromContext(ctx); sig != nil {
+		t.Errorf("want nil, got %v", sig)
+	}
+
+	sigCh <- syscall.SIGTERM
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context is not cancelled on signal")
+	}
+
+	if want, got := syscall.SIGTERM, SignalFromContext(ctx); want != got {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestSetupSignalContextWithParentCancel(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := SetupSignalContext(parent, true)
+	defer cancel()
+
+	cancelParent()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context is not cancelled on parent cancel")
+	}
+
+	if sig := SignalFromContext(ctx); sig != nil {
+		t.Errorf("want nil, got %v", sig)
+	}
+}
+
+func TestSetupSignalContextWithCancel(t *testing.T) {
+	sigCh := notifySignal()
+	registered := func() bool {
+		sigChsMu.Lock()
+		defer sigChsMu.Unlock()
+		for _, ch := range sigChs {
+			if ch == sigCh {
+				return true
+			}
+		}
+		return false
+	}
+
+	ctx, cancel := setupSignalContext(context.Background(), sigCh, true)
+	cancel()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context is not cancelled on cancel")
+	}
+
+	// the registration is released asynchronously
+	deadline := time.Now().Add(time.Second)
+	for registered() {
+		if time.Now().After(deadline) {
+			t.Fatal("the signal handler is not released on cancel")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSetupSignalContextIgnoresSecondSignal(t *testing.T) {
+	// the real signals are sent in a subprocess, since the other handlers in this process exit on them
+	if os.Getenv("TEST_SIGNAL_SUBPROCESS") == "1" {
+		ctx, cancel := SetupSignalContext(context.Background(), false)
+		defer cancel()
+
+		for i := 0; i < 2; i++ {
+			if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		select {
+		case <-ctx.Done():
+		default:
+			t.Fatal("context is not cancelled on signal")
+		}
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSetupSignalContextIgnoresSecondSignal$")
+	cmd.Env = append(os.Environ(), "TEST_SIGNAL_SUBPROCESS=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("the process is terminated by the second signal: %v\n%s", err, out)
+	}
+}
+
+func TestContextWithStopCh(t *testing.T) {
+	stopCh := make(chan struct{})
+	ctx, cancel := ContextWithStopCh(stopCh)
+	defer cancel()
+
+	close(stopCh)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context is not cancelled on closing stopCh")
+	}
+}
@@ -2,40 +2,210 @@ package util
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc/metadata"
 )
 
 var (
 	// For trace header, see https://cloud.google.com/trace/docs/troubleshooting#force-trace
-	traceHeaderRegExp = regexp.MustCompile(`^\s*([0-9a-fA-F]+)(?:/(\d+))?(?:;o=[01])?\s*$`)
+	traceHeaderRegExp = regexp.MustCompile(`^\s*([0-9a-fA-F]+)(?:/(\d+))?(?:;o=([01]))?\s*$`)
+	// For traceparent header, see https://www.w3.org/TR/trace-context/#traceparent-header
+	traceparentRegExp  = regexp.MustCompile(`^\s*([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?\s*$`)
+	validTraceIDRegExp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	// math/rand is enough for span ids, and it is guarded because rand.Rand is not safe for concurrent use
+	spanIDMu   sync.Mutex
+	spanIDRand = rand.New(rand.NewSource(time.Now().UnixNano()))
 )
 
-func GetTraceIDFromHeader(header string) string {
-	matched := traceHeaderRegExp.FindStringSubmatch(header)
-	if len(matched) < 3 {
+// SpanContext is the trace context propagated from the caller.
+type SpanContext struct {
+	TraceID string
+	// SpanID is 16 characters hex string, which is the format of Cloud Logging
+	SpanID  string
+	Sampled bool
+	// SampledUnknown is true when X-Cloud-Trace-Context has no o flag, i.e. the caller hasn't decided the sampling,
+	// which is propagated without the flag
+	SampledUnknown bool
+	// TraceState is the raw value of tracestate header, which is propagated as it is
+	TraceState string
+}
+
+// CloudTraceContext formats sc as X-Cloud-Trace-Context header.
+func (sc *SpanContext) CloudTraceContext() string {
+	header := sc.TraceID
+	if spanID, err := strconv.ParseUint(sc.SpanID, 16, 64); err == nil {
+		header += "/" + strconv.FormatUint(spanID, 10)
+	}
+	if sc.SampledUnknown {
+		return header
+	}
+	if sc.Sampled {
+		return header + ";o=1"
+	}
+	return header + ";o=0"
+}
+
+// NewChild returns SpanContext of a new span in the same trace, whose span id is random.
+func (sc *SpanContext) NewChild() *SpanContext {
+	child := *sc
+	child.SpanID = newSpanID()
+	return &child
+}
+
+func newSpanID() string {
+	spanIDMu.Lock()
+	defer spanIDMu.Unlock()
+
+	// 0 is invalid span id
+	var id uint64
+	for id == 0 {
+		id = spanIDRand.Uint64()
+	}
+	return fmt.Sprintf("%016x", id)
+}
+
+// Traceparent formats sc as traceparent header.
+// It returns empty string if sc can't be represented by traceparent, e.g. the trace id is not 32 characters.
+func (sc *SpanContext) Traceparent() string {
+	traceID := strings.ToLower(sc.TraceID)
+	if !validTraceIDRegExp.MatchString(traceID) || len(sc.SpanID) != 16 {
 		return ""
 	}
 
-	return matched[1]
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", traceID, sc.SpanID, flags)
 }
 
-func GetTraceIDFromMetadata(ctx context.Context) string {
+// GetSpanContextFromTraceparent parses traceparent and tracestate headers. It returns nil if traceparent is invalid.
+func GetSpanContextFromTraceparent(traceparent, tracestate string) *SpanContext {
+	matched := traceparentRegExp.FindStringSubmatch(traceparent)
+	if len(matched) < 6 {
+		return nil
+	}
+
+	version, traceID, spanID, flags, rest := matched[1], matched[2], matched[3], matched[4], matched[5]
+	// version 00 doesn't have further fields, and ff is invalid
+	if version == "ff" || (version == "00" && rest != "") {
+		return nil
+	}
+	if traceID == strings.Repeat("0", 32) || spanID == strings.Repeat("0", 16) {
+		return nil
+	}
+
+	f, err := strconv.ParseUint(flags, 16, 8)
+	if err != nil {
+		return nil
+	}
+
+	return &SpanContext{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		Sampled:    f&0x01 == 0x01,
+		TraceState: tracestate,
+	}
+}
+
+// GetSpanContextFromHTTPHeader parses traceparent header, or X-Cloud-Trace-Context header if traceparent is absent or invalid.
+// It returns nil if neither is valid.
+func GetSpanContextFromHTTPHeader(h http.Header) *SpanContext {
+	if sc := GetSpanContextFromTraceparent(h.Get("traceparent"), strings.Join(h.Values("tracestate"), ",")); sc != nil {
+		return sc
+	}
+
+	return GetSpanContextFromHeader(h.Get("X-Cloud-Trace-Context"))
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx with sc, which is propagated to outgoing requests.
+func ContextWithSpanContext(ctx context.Context, sc *SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns SpanContext stored by ContextWithSpanContext. It returns nil if not found.
+func SpanContextFromContext(ctx context.Context) *SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(*SpanContext)
+	return sc
+}
+
+// GetSpanContextFromHeader parses X-Cloud-Trace-Context header. It returns nil if the header is invalid.
+func GetSpanContextFromHeader(header string) *SpanContext {
+	matched := traceHeaderRegExp.FindStringSubmatch(header)
+	if len(matched) < 4 {
+		return nil
+	}
+
+	sc := &SpanContext{
+		TraceID:        matched[1],
+		Sampled:        matched[3] == "1",
+		SampledUnknown: matched[3] == "",
+	}
+
+	// span id of the header is decimal
+	if matched[2] != "" {
+		spanID, err := strconv.ParseUint(matched[2], 10, 64)
+		if err != nil {
+			return nil
+		}
+		sc.SpanID = fmt.Sprintf("%016x", spanID)
+	}
+
+	return sc
+}
+
+// GetSpanContextFromMetadata parses traceparent metadata, or x-cloud-trace-context metadata if traceparent is absent or invalid.
+// It returns nil if neither is valid.
+func GetSpanContextFromMetadata(ctx context.Context) *SpanContext {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return ""
+		return nil
+	}
+
+	if values := md.Get("traceparent"); len(values) == 1 {
+		var tracestate string
+		if states := md.Get("tracestate"); len(states) > 0 {
+			tracestate = strings.Join(states, ",")
+		}
+		if sc := GetSpanContextFromTraceparent(values[0], tracestate); sc != nil {
+			return sc
+		}
 	}
 
 	values := md.Get("x-cloud-trace-context")
 	if len(values) != 1 {
+		return nil
+	}
+
+	return GetSpanContextFromHeader(values[0])
+}
+
+func GetTraceIDFromHeader(header string) string {
+	sc := GetSpanContextFromHeader(header)
+	if sc == nil {
 		return ""
 	}
 
-	matched := traceHeaderRegExp.FindStringSubmatch(values[0])
-	if len(matched) < 3 {
+	return sc.TraceID
+}
+
+func GetTraceIDFromMetadata(ctx context.Context) string {
+	sc := GetSpanContextFromMetadata(ctx)
+	if sc == nil {
 		return ""
 	}
 
-	return matched[1]
+	return sc.TraceID
 }
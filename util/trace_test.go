@@ -2,6 +2,8 @@ package util
 
 import (
 	"context"
+	"net/http"
+	"reflect"
 	"testing"
 
 	"google.golang.org/grpc/metadata"
@@ -50,3 +52,154 @@ func TestGetTraceIDFromMetadata(t *testing.T) {
 	}
 
 }
+
+func TestGetSpanContextFromHeader(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		want   *SpanContext
+	}{
+		{"0123456789abcdef0123456789abcdef/123;o=1", &SpanContext{TraceID: "0123456789abcdef0123456789abcdef", SpanID: "000000000000007b", Sampled: true}},
+		{"0123456789abcdef0123456789abcdef/18446744073709551615;o=0", &SpanContext{TraceID: "0123456789abcdef0123456789abcdef", SpanID: "ffffffffffffffff", Sampled: false}},
+		{"0123456789abcdef0123456789abcdef/123", &SpanContext{TraceID: "0123456789abcdef0123456789abcdef", SpanID: "000000000000007b", Sampled: false, SampledUnknown: true}},
+		{"0123456789abcdef0123456789abcdef", &SpanContext{TraceID: "0123456789abcdef0123456789abcdef", SpanID: "", Sampled: false, SampledUnknown: true}},
+		{"0123456789abcdef0123456789abcdef/18446744073709551616", nil},
+		{"0123456789abcdef0123456789abcdef/invalid", nil},
+		{"invalid", nil},
+		{"", nil},
+	} {
+		sc := GetSpanContextFromHeader(tt.header)
+		if !reflect.DeepEqual(sc, tt.want) {
+			t.Errorf("GetSpanContextFromHeader(%q) = (%v), want = (%v)", tt.header, sc, tt.want)
+		}
+	}
+}
+
+func TestGetSpanContextFromTraceparent(t *testing.T) {
+	for _, tt := range []struct {
+		traceparent string
+		tracestate  string
+		want        *SpanContext
+	}{
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "congo=t61rcWkgMzE", &SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true, TraceState: "congo=t61rcWkgMzE"}},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00", "", &SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: false}},
+		{"01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-03-future", "", &SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true}},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-future", "", nil},
+		{"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "", nil},
+		{"00-00000000000000000000000000000000-b7ad6b7169203331-01", "", nil},
+		{"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01", "", nil},
+		{"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01", "", nil},
+		{"invalid", "", nil},
+		{"", "", nil},
+	} {
+		sc := GetSpanContextFromTraceparent(tt.traceparent, tt.tracestate)
+		if !reflect.DeepEqual(sc, tt.want) {
+			t.Errorf("GetSpanContextFromTraceparent(%q, %q) = (%v), want = (%v)", tt.traceparent, tt.tracestate, sc, tt.want)
+		}
+	}
+}
+
+func TestGetSpanContextFromHTTPHeader(t *testing.T) {
+	for _, tt := range []struct {
+		header http.Header
+		want   *SpanContext
+	}{
+		{
+			http.Header{
+				"Traceparent":           {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+				"X-Cloud-Trace-Context": {"0123456789abcdef0123456789abcdef/123;o=0"},
+			},
+			&SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true},
+		},
+		{
+			http.Header{
+				"Traceparent":           {"invalid"},
+				"X-Cloud-Trace-Context": {"0123456789abcdef0123456789abcdef/123;o=0"},
+			},
+			&SpanContext{TraceID: "0123456789abcdef0123456789abcdef", SpanID: "000000000000007b", Sampled: false},
+		},
+		{
+			http.Header{},
+			nil,
+		},
+	} {
+		sc := GetSpanContextFromHTTPHeader(tt.header)
+		if !reflect.DeepEqual(sc, tt.want) {
+			t.Errorf("GetSpanContextFromHTTPHeader(%v) = (%v), want = (%v)", tt.header, sc, tt.want)
+		}
+	}
+}
+
+func TestGetSpanContextFromMetadata(t *testing.T) {
+	md := metadata.New(map[string]string{
+		"traceparent":           "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"tracestate":            "congo=t61rcWkgMzE",
+		"x-cloud-trace-context": "0123456789abcdef0123456789abcdef/123;o=0",
+	})
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	want := &SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true, TraceState: "congo=t61rcWkgMzE"}
+	if sc := GetSpanContextFromMetadata(ctx); !reflect.DeepEqual(sc, want) {
+		t.Errorf("GetSpanContextFromMetadata() = (%v), want = (%v)", sc, want)
+	}
+}
+
+func TestFormatSpanContext(t *testing.T) {
+	for _, tt := range []struct {
+		sc                    *SpanContext
+		wantCloudTraceContext string
+		wantTraceparent       string
+	}{
+		{
+			&SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "000000000000007b", Sampled: true},
+			"0af7651916cd43dd8448eb211c80319c/123;o=1",
+			"00-0af7651916cd43dd8448eb211c80319c-000000000000007b-01",
+		},
+		{
+			&SpanContext{TraceID: "0123456789abcdef", SpanID: "000000000000007b"},
+			"0123456789abcdef/123;o=0",
+			"",
+		},
+		{
+			&SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c"},
+			"0af7651916cd43dd8448eb211c80319c;o=0",
+			"",
+		},
+		{
+			// the header without o flag is propagated without the flag
+			GetSpanContextFromHeader("0123456789abcdef/123"),
+			"0123456789abcdef/123",
+			"",
+		},
+		{
+			GetSpanContextFromHeader("0af7651916cd43dd8448eb211c80319c/123"),
+			"0af7651916cd43dd8448eb211c80319c/123",
+			"00-0af7651916cd43dd8448eb211c80319c-000000000000007b-00",
+		},
+	} {
+		if want, got := tt.wantCloudTraceContext, tt.sc.CloudTraceContext(); want != got {
+			t.Errorf("CloudTraceContext() = %q, want = %q", got, want)
+		}
+		if want, got := tt.wantTraceparent, tt.sc.Traceparent(); want != got {
+			t.Errorf("Traceparent() = %q, want = %q", got, want)
+		}
+	}
+}
+
+func TestNewChild(t *testing.T) {
+	sc := &SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "000000000000007b", Sampled: true, TraceState: "congo=t61rcWkgMzE"}
+
+	child := sc.NewChild()
+	if child.TraceID != sc.TraceID || child.Sampled != sc.Sampled || child.TraceState != sc.TraceState {
+		t.Errorf("NewChild() = (%v), want the same trace as (%v)", child, sc)
+	}
+	if len(child.SpanID) != 16 || child.SpanID == sc.SpanID {
+		t.Errorf("NewChild() has invalid span id %q", child.SpanID)
+	}
+	if another := sc.NewChild(); another.SpanID == child.SpanID {
+		t.Errorf("NewChild() returns the same span id %q", child.SpanID)
+	}
+	// sc is not modified
+	if want := "000000000000007b"; sc.SpanID != want {
+		t.Errorf("SpanID = %q, want = %q", sc.SpanID, want)
+	}
+}
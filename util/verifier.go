@@ -0,0 +1,144 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+)
+
+var (
+	// ErrInvalidIDToken is returned when the ID token is missing, malformed, expired or not signed by Google.
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	// ErrEmailNotAllowed is returned when the ID token is valid but the caller is not allowed.
+	ErrEmailNotAllowed = errors.New("email is not allowed")
+)
+
+// Google-signed ID tokens have either issuer, see https://developers.google.com/identity/protocols/oauth2/openid-connect#validatinganidtoken
+var googleIssuers = map[string]bool{
+	"accounts.google.com":         true,
+	"https://accounts.google.com": true,
+}
+
+// IDTokenValidator validates the signature, audience and expiry of ID token, which is implemented by idtoken.Validator.
+type IDTokenValidator interface {
+	Validate(ctx context.Context, idToken string, audience string) (*idtoken.Payload, error)
+}
+
+type verifierOptions struct {
+	allowedEmails map[string]bool
+	validator     IDTokenValidator
+	clientOptions []option.ClientOption
+}
+
+type IDTokenVerifierOption func(*verifierOptions)
+
+// WithAllowedEmails allows only the callers of the emails, e.g. the service accounts of other services.
+// All callers having valid ID tokens are allowed by default.
+func WithAllowedEmails(emails ...string) IDTokenVerifierOption {
+	return func(o *verifierOptions) {
+		if o.allowedEmails == nil {
+			o.allowedEmails = make(map[string]bool, len(emails))
+		}
+		for _, email := range emails {
+			o.allowedEmails[email] = true
+		}
+	}
+}
+
+// WithValidator replaces the validator of ID tokens, e.g. in tests.
+func WithValidator(v IDTokenValidator) IDTokenVerifierOption {
+	return func(o *verifierOptions) {
+		o.validator = v
+	}
+}
+
+// WithValidatorClientOptions configures the HTTP client fetching Google's public keys.
+func WithValidatorClientOptions(opts ...option.ClientOption) IDTokenVerifierOption {
+	return func(o *verifierOptions) {
+		o.clientOptions = append(o.clientOptions, opts...)
+	}
+}
+
+// IDTokenVerifier verifies Google-signed ID tokens sent by other services.
+// The public keys are cached until they expire, so it should be created once and shared.
+type IDTokenVerifier struct {
+	audience      string
+	allowedEmails map[string]bool
+	validator     IDTokenValidator
+}
+
+// NewIDTokenVerifier creates IDTokenVerifier accepting ID tokens issued for audience, e.g. the URL of the service https://xxx.a.run.app.
+func NewIDTokenVerifier(ctx context.Context, audience string, opts ...IDTokenVerifierOption) (*IDTokenVerifier, error) {
+	if audience == "" {
+		return nil, errors.New("audience must not be empty")
+	}
+
+	o := &verifierOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.validator == nil {
+		// the public keys don't require credentials
+		validator, err := idtoken.NewValidator(ctx, append([]option.ClientOption{option.WithoutAuthentication()}, o.clientOptions...)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ID token validator: %w", err)
+		}
+		o.validator = validator
+	}
+
+	return &IDTokenVerifier{
+		audience:      audience,
+		allowedEmails: o.allowedEmails,
+		validator:     o.validator,
+	}, nil
+}
+
+// Verify verifies the signature, audience, issuer and expiry of token, and the email if allowed emails are set.
+// The returned error wraps either ErrInvalidIDToken or ErrEmailNotAllowed.
+func (v *IDTokenVerifier) Verify(ctx context.Context, token string) (*idtoken.Payload, error) {
+	payload, err := v.validator.Validate(ctx, token, v.audience)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if !googleIssuers[payload.Issuer] {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, payload.Issuer)
+	}
+
+	if v.allowedEmails != nil {
+		email, _ := payload.Claims["email"].(string)
+		verified, _ := payload.Claims["email_verified"].(bool)
+		if !verified || !v.allowedEmails[email] {
+			return nil, fmt.Errorf("%w: %q", ErrEmailNotAllowed, email)
+		}
+	}
+
+	return payload, nil
+}
+
+// VerifyAuthorization verifies the bearer token of Authorization header.
+func (v *IDTokenVerifier) VerifyAuthorization(ctx context.Context, authorization string) (*idtoken.Payload, error) {
+	const prefix = "bearer "
+	if len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return nil, fmt.Errorf("%w: bearer token not found", ErrInvalidIDToken)
+	}
+
+	return v.Verify(ctx, strings.TrimSpace(authorization[len(prefix):]))
+}
+
+type idTokenPayloadKey struct{}
+
+// ContextWithIDTokenPayload returns a copy of ctx with the verified claims.
+func ContextWithIDTokenPayload(ctx context.Context, payload *idtoken.Payload) context.Context {
+	return context.WithValue(ctx, idTokenPayloadKey{}, payload)
+}
+
+// IDTokenPayloadFromContext returns the claims verified by the middleware. It returns nil if not found.
+func IDTokenPayloadFromContext(ctx context.Context) *idtoken.Payload {
+	payload, _ := ctx.Value(idTokenPayloadKey{}).(*idtoken.Payload)
+	return payload
+}
@@ -0,0 +1,184 @@
+package util
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+)
+
+// rewriteTransport sends all requests to the fake server.
+type rewriteTransport struct {
+	host string
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = t.host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newFakeCertsServer serves the public key of key as Google's certs endpoint, and returns the client to fetch it.
+func newFakeCertsServer(t *testing.T, key *rsa.PrivateKey) *http.Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"alg": "RS256",
+				"kty": "RSA",
+				"use": "sig",
+				"kid": "test-key",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	return &http.Client{Transport: &rewriteTransport{host: srv.Listener.Addr().String()}}
+}
+
+func signTestIDToken(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT","kid":"test-key"}`))
+	b, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := header + "." + base64.RawURLEncoding.EncodeToString(b)
+
+	hashed := sha256.Sum256([]byte(content))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return content + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestIDTokenVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	verifier, err := NewIDTokenVerifier(ctx, "https://example.a.run.app",
+		WithAllowedEmails("caller@sample-project.iam.gserviceaccount.com"),
+		WithValidatorClientOptions(option.WithHTTPClient(newFakeCertsServer(t, key))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":            "https://accounts.google.com",
+			"aud":            "https://example.a.run.app",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"iat":            time.Now().Unix(),
+			"sub":            "1234567890",
+			"email":          "caller@sample-project.iam.gserviceaccount.com",
+			"email_verified": true,
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", token: signTestIDToken(t, key, claims(nil))},
+		{name: "issuer without scheme", token: signTestIDToken(t, key, claims(map[string]interface{}{"iss": "accounts.google.com"}))},
+		{name: "wrong signature", token: signTestIDToken(t, otherKey, claims(nil)), wantErr: ErrInvalidIDToken},
+		{name: "wrong audience", token: signTestIDToken(t, key, claims(map[string]interface{}{"aud": "https://other.a.run.app"})), wantErr: ErrInvalidIDToken},
+		{name: "wrong issuer", token: signTestIDToken(t, key, claims(map[string]interface{}{"iss": "https://example.com"})), wantErr: ErrInvalidIDToken},
+		{name: "expired", token: signTestIDToken(t, key, claims(map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()})), wantErr: ErrInvalidIDToken},
+		{name: "malformed", token: "malformed", wantErr: ErrInvalidIDToken},
+		{name: "not allowed email", token: signTestIDToken(t, key, claims(map[string]interface{}{"email": "other@sample-project.iam.gserviceaccount.com"})), wantErr: ErrEmailNotAllowed},
+		{name: "unverified email", token: signTestIDToken(t, key, claims(map[string]interface{}{"email_verified": false})), wantErr: ErrEmailNotAllowed},
+	}
+
+	for _, tt := range tests {
+		payload, err := verifier.Verify(ctx, tt.token)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: want error %v, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", tt.name, err)
+			continue
+		}
+		if want, got := "1234567890", payload.Subject; want != got {
+			t.Errorf("%s: wrong subject %q, want %q", tt.name, got, want)
+		}
+	}
+}
+
+type fakeValidator struct{}
+
+func (fakeValidator) Validate(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+	if token != "valid-token" {
+		return nil, errors.New("invalid")
+	}
+	return &idtoken.Payload{Issuer: "https://accounts.google.com", Audience: audience}, nil
+}
+
+func TestVerifyAuthorization(t *testing.T) {
+	verifier, err := NewIDTokenVerifier(context.Background(), "https://example.a.run.app", WithValidator(fakeValidator{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for authorization, wantErr := range map[string]error{
+		"Bearer valid-token":   nil,
+		"bearer valid-token":   nil,
+		"Bearer invalid-token": ErrInvalidIDToken,
+		"Basic dXNlcjpwYXNz":   ErrInvalidIDToken,
+		"Bearer ":              ErrInvalidIDToken,
+		"":                     ErrInvalidIDToken,
+	} {
+		if _, err := verifier.VerifyAuthorization(context.Background(), authorization); !errors.Is(err, wantErr) {
+			t.Errorf("VerifyAuthorization(%q) error = %v, want %v", authorization, err, wantErr)
+		}
+	}
+}
+
+func TestNewIDTokenVerifierWithoutAudience(t *testing.T) {
+	if _, err := NewIDTokenVerifier(context.Background(), "", WithValidator(fakeValidator{})); err == nil {
+		t.Error("error is expected without audience")
+	}
+}
+
+func TestIDTokenPayloadFromContext(t *testing.T) {
+	if got := IDTokenPayloadFromContext(context.Background()); got != nil {
+		t.Errorf("IDTokenPayloadFromContext() = %v, want nil", got)
+	}
+
+	payload := &idtoken.Payload{Subject: "1234567890"}
+	if got := IDTokenPayloadFromContext(ContextWithIDTokenPayload(context.Background(), payload)); got != payload {
+		t.Errorf("IDTokenPayloadFromContext() = %v, want %v", got, payload)
+	}
+}